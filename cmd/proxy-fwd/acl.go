@@ -0,0 +1,265 @@
+package main
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ACLRules is a set of destinations
+type ACLRules struct {
+	Domains []string `yaml:"domains,omitempty" json:"domains,omitempty"` // suffixes: "example.com" matches subdomains
+	CIDRs   []string `yaml:"cidrs,omitempty" json:"cidrs,omitempty"`     // "10.0.0.0/8" or a single IP
+	Ports   []string `yaml:"ports,omitempty" json:"ports,omitempty"`     // "25" or "8000-9000"
+}
+
+// DestACL limits where a local port may connect. A destination is refused
+// when it matches any Deny entry, or when Allow lists hosts (domains/CIDRs)
+// or ports and the destination matches none of them.
+type DestACL struct {
+	Allow ACLRules `yaml:"allow,omitempty" json:"allow"`
+	Deny  ACLRules `yaml:"deny,omitempty" json:"deny"`
+}
+
+// empty reports whether the ACL has no entries
+func (a *DestACL) empty() bool {
+	return a == nil || (a.Allow.empty() && a.Deny.empty())
+}
+
+func (r *ACLRules) empty() bool {
+	return len(r.Domains) == 0 && len(r.CIDRs) == 0 && len(r.Ports) == 0
+}
+
+// aclPortRange is an inclusive destination port range
+type aclPortRange struct{ lo, hi int }
+
+// compiledACLRules is ACLRules ready for matching
+type compiledACLRules struct {
+	domains  []string
+	prefixes []netip.Prefix
+	ports    []aclPortRange
+}
+
+// compiledACL is a DestACL ready for matching; a nil *compiledACL allows everything
+type compiledACL struct {
+	allow, deny compiledACLRules
+}
+
+// parsePortRange parses "443" or "8000-9000"
+func parsePortRange(s string) (aclPortRange, error) {
+	s = strings.TrimSpace(s)
+	lo, hi, isRange := strings.Cut(s, "-")
+	a, err := strconv.Atoi(strings.TrimSpace(lo))
+	b := a
+	if err == nil && isRange {
+		b, err = strconv.Atoi(strings.TrimSpace(hi))
+	}
+	if err != nil || a < 1 || b > 65535 || a > b {
+		return aclPortRange{}, invalidf("invalid port %q", s)
+	}
+	return aclPortRange{a, b}, nil
+}
+
+// parsePrefix parses a CIDR or a single IP address
+func parsePrefix(s string) (netip.Prefix, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "/") {
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return p, invalidf("invalid CIDR %q", s)
+		}
+		return p.Masked(), nil
+	}
+	ip, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, invalidf("invalid IP %q", s)
+	}
+	return netip.PrefixFrom(ip, ip.BitLen()), nil
+}
+
+func compileACLRules(r *ACLRules) (compiledACLRules, error) {
+	var c compiledACLRules
+	for _, d := range r.Domains {
+		if d = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(d)), "."); d != "" {
+			c.domains = append(c.domains, d)
+		}
+	}
+	for _, s := range r.CIDRs {
+		p, err := parsePrefix(s)
+		if err != nil {
+			return c, err
+		}
+		c.prefixes = append(c.prefixes, p)
+	}
+	for _, s := range r.Ports {
+		pr, err := parsePortRange(s)
+		if err != nil {
+			return c, err
+		}
+		c.ports = append(c.ports, pr)
+	}
+	return c, nil
+}
+
+// compileACL validates and compiles an ACL; nil or empty ACLs compile to nil
+func compileACL(a *DestACL) (*compiledACL, error) {
+	if a.empty() {
+		return nil, nil
+	}
+	allow, err := compileACLRules(&a.Allow)
+	if err != nil {
+		return nil, invalidf("allow: %v", err)
+	}
+	deny, err := compileACLRules(&a.Deny)
+	if err != nil {
+		return nil, invalidf("deny: %v", err)
+	}
+	return &compiledACL{allow: allow, deny: deny}, nil
+}
+
+// matchHost reports whether host is one of the domains or inside one of the prefixes
+func (c *compiledACLRules) matchHost(host string) bool {
+	if ip, err := netip.ParseAddr(host); err == nil {
+		ip = ip.Unmap()
+		for _, p := range c.prefixes {
+			if p.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, d := range c.domains {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *compiledACLRules) matchPort(port int) bool {
+	for _, r := range c.ports {
+		if port >= r.lo && port <= r.hi {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIP returns why a resolved destination address is refused by a deny
+// CIDR, or ""; direct routes call it at dial time so that host names
+// resolving into a denied range are refused too
+func (c *compiledACL) checkIP(ip netip.Addr) string {
+	if c == nil {
+		return ""
+	}
+	ip = ip.Unmap()
+	for _, p := range c.deny.prefixes {
+		if p.Contains(ip) {
+			return "destination address denied"
+		}
+	}
+	return ""
+}
+
+// check returns why host:port is refused, or "" if it is allowed
+func (c *compiledACL) check(host string, port int) string {
+	if c == nil {
+		return ""
+	}
+	host = strings.TrimSuffix(strings.ToLower(strings.Trim(host, "[]")), ".")
+	if c.deny.matchHost(host) {
+		return "destination host denied"
+	}
+	if c.deny.matchPort(port) {
+		return "destination port denied"
+	}
+	if (len(c.allow.domains) > 0 || len(c.allow.prefixes) > 0) && !c.allow.matchHost(host) {
+		return "destination host not allowed"
+	}
+	if len(c.allow.ports) > 0 && !c.allow.matchPort(port) {
+		return "destination port not allowed"
+	}
+	return ""
+}
+
+// setGlobalACL validates and stores the ACL applied to every local port
+func (m *Manager) setGlobalACL(a *DestACL) (*DestACL, error) {
+	c, err := compileACL(a)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, _ = m.snapshotLocked("acl", false)
+	m.setGlobalACLLocked(a, c)
+	return a, m.saveState()
+}
+
+// setGlobalACLLocked installs an already compiled global ACL (running
+// listeners see it on their next request)
+func (m *Manager) setGlobalACLLocked(a *DestACL, c *compiledACL) {
+	if a.empty() {
+		a = nil
+	}
+	m.acl = a
+	m.aclCompiled.Store(c)
+}
+
+// loadGlobalACLLocked installs the ACL read from a state file
+func (m *Manager) loadGlobalACLLocked(a *DestACL) {
+	c, err := compileACL(a)
+	if err != nil {
+		log.Printf("[ACL] global ACL ignored: %v", err)
+		// keep the file's entries so they are not lost on the next save
+		m.acl = a
+		m.aclCompiled.Store(nil)
+		return
+	}
+	m.setGlobalACLLocked(a, c)
+}
+
+// handleV1ACLGet returns the global destination ACL
+func (m *Manager) handleV1ACLGet(w http.ResponseWriter, r *http.Request) {
+	m.mu.RLock()
+	a := m.acl
+	m.mu.RUnlock()
+	if a == nil {
+		a = &DestACL{}
+	}
+	writeJSON(w, http.StatusOK, a)
+}
+
+// handleV1ACLPut replaces the global destination ACL
+// Body: {"allow": {"ports": ["80", "443"]}, "deny": {"cidrs": ["10.0.0.0/8"], "domains": ["example.com"]}}
+func (m *Manager) handleV1ACLPut(w http.ResponseWriter, r *http.Request) {
+	var a DestACL
+	if !decodeJSON(w, r, &a) {
+		return
+	}
+	res, err := m.setGlobalACL(&a)
+	if err != nil {
+		writeManagerError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// aclEqual compares ACLs, treating nil and empty alike
+func aclEqual(a, b *DestACL) bool {
+	if a.empty() || b.empty() {
+		return a.empty() && b.empty()
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// clientAddr returns the client IP of a proxied request for logging
+func clientAddr(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
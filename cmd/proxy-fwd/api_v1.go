@@ -0,0 +1,381 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Stable error codes returned by /api/v1
+const (
+	codeUnauthorized = "unauthorized"
+	codeBadRequest   = "bad_request"
+	codeInvalidJSON  = "invalid_json"
+	codeNotFound     = "not_found"
+	codeConflict     = "conflict"
+	codeNotRunning   = "not_running"
+	codeUpstream     = "upstream_error"
+	codeInternal     = "internal"
+)
+
+var errExists = errors.New("proxy already exists")
+
+// invalidError marks validation failures so handlers can answer 400
+type invalidError struct{ msg string }
+
+func (e *invalidError) Error() string { return e.msg }
+
+// invalidf formats a validation error
+func invalidf(format string, a ...any) error {
+	return &invalidError{msg: fmt.Sprintf(format, a...)}
+}
+
+// apiErrorBody is the JSON error envelope of /api/v1
+type apiErrorBody struct {
+	Error struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// writeJSON writes v as JSON with the given status
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeAPIError writes a structured JSON error
+func writeAPIError(w http.ResponseWriter, status int, code, msg string) {
+	var body apiErrorBody
+	body.Error.Code = code
+	body.Error.Message = msg
+	writeJSON(w, status, body)
+}
+
+// writeManagerError maps Manager errors to HTTP status and error code. A
+// missing file (e.g. the state directory) is a server error, not a 404.
+func writeManagerError(w http.ResponseWriter, err error) {
+	var invalid *invalidError
+	var pathErr *fs.PathError
+	switch {
+	case errors.As(err, &invalid):
+		writeAPIError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+	case errors.As(err, &pathErr):
+		writeAPIError(w, http.StatusInternalServerError, codeInternal, err.Error())
+	case errors.Is(err, os.ErrNotExist):
+		writeAPIError(w, http.StatusNotFound, codeNotFound, "proxy not found")
+	case errors.Is(err, errExists):
+		writeAPIError(w, http.StatusConflict, codeConflict, err.Error())
+	default:
+		writeAPIError(w, http.StatusInternalServerError, codeInternal, err.Error())
+	}
+}
+
+// nameRe is the syntax of user-chosen names: PAC profiles, remote lists and
+// secrets; they appear in URL paths and as provider names
+var nameRe = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// decodeJSON reads a JSON request body into v, writing an error on failure
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(io.LimitReader(r.Body, 4<<20)).Decode(v); err != nil {
+		writeAPIError(w, http.StatusBadRequest, codeInvalidJSON, "invalid JSON: "+err.Error())
+		return false
+	}
+	return true
+}
+
+// v1 wraps an /api/v1 handler with admin token authentication
+func (m *Manager) v1(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !m.handleAuth(r) {
+			writeAPIError(w, http.StatusUnauthorized, codeUnauthorized, "missing or invalid admin token")
+			return
+		}
+		h(w, r)
+	}
+}
+
+// registerV1 adds the resource-oriented /api/v1 routes to mux
+func (m *Manager) registerV1(mux *router) {
+	mux.HandleFunc("GET /api/v1/proxies", m.v1(m.handleV1List))
+	mux.HandleFunc("POST /api/v1/proxies", m.v1(m.handleV1Create))
+	mux.HandleFunc("GET /api/v1/proxies/{id}", m.v1(m.handleV1Get))
+	mux.HandleFunc("PATCH /api/v1/proxies/{id}", m.v1(m.handleV1Patch))
+	mux.HandleFunc("DELETE /api/v1/proxies/{id}", m.v1(m.handleV1Delete))
+	mux.HandleFunc("POST /api/v1/proxies/{id}/start", m.v1(m.handleV1Start))
+	mux.HandleFunc("POST /api/v1/proxies/{id}/stop", m.v1(m.handleV1Stop))
+	mux.HandleFunc("GET /api/v1/proxies/{id}/exit-ip", m.v1(m.handleV1ExitIP))
+	mux.HandleFunc("POST /api/v1/proxies/{id}/local-auth", m.v1(m.handleV1LocalAuthSet))
+	mux.HandleFunc("DELETE /api/v1/proxies/{id}/local-auth", m.v1(m.handleV1LocalAuthDelete))
+	mux.HandleFunc("POST /api/v1/bulk/add", m.v1(m.handleV1BulkAdd))
+	mux.HandleFunc("POST /api/v1/bulk/{op}", m.v1(m.handleV1Bulk))
+	mux.HandleFunc("POST /api/v1/import", m.v1(m.handleV1Import))
+	mux.HandleFunc("GET /api/v1/lan", m.v1(m.handleV1LAN))
+	mux.HandleFunc("GET /api/v1/remote-lists", m.v1(m.handleV1RemoteLists))
+	mux.HandleFunc("PUT /api/v1/remote-lists/{name}", m.v1(m.handleV1RemoteListPut))
+	mux.HandleFunc("DELETE /api/v1/remote-lists/{name}", m.v1(m.handleV1RemoteListDelete))
+	mux.HandleFunc("POST /api/v1/remote-lists/{name}/sync", m.v1(m.handleV1RemoteListSync))
+	mux.HandleFunc("POST /api/v1/proxies/{id}/renew", m.v1(m.handleV1Renew))
+	mux.HandleFunc("GET /api/v1/expiring", m.v1(m.handleV1Expiring))
+	mux.HandleFunc("GET /api/v1/schedules", m.v1(m.handleV1Schedules))
+	mux.HandleFunc("GET /api/v1/schedules/{name}", m.v1(m.handleV1Schedule))
+	mux.HandleFunc("PUT /api/v1/schedules/{name}", m.v1(m.handleV1SchedulePut))
+	mux.HandleFunc("DELETE /api/v1/schedules/{name}", m.v1(m.handleV1ScheduleDelete))
+	mux.HandleFunc("POST /api/v1/schedules/{name}/run", m.v1(m.handleV1ScheduleRun))
+
+	mux.HandleFunc("GET /api/v1/secrets", m.v1(m.handleV1Secrets))
+	mux.HandleFunc("PUT /api/v1/secrets/{name}", m.v1(m.handleV1SecretPut))
+	mux.HandleFunc("DELETE /api/v1/secrets/{name}", m.v1(m.handleV1SecretDelete))
+	mux.HandleFunc("GET /api/v1/jobs", m.v1(m.handleV1Jobs))
+	mux.HandleFunc("POST /api/v1/jobs", m.v1(m.handleV1JobCreate))
+	mux.HandleFunc("GET /api/v1/jobs/{id}", m.v1(m.handleV1Job))
+	mux.HandleFunc("POST /api/v1/jobs/{id}/cancel", m.v1(m.handleV1JobCancel))
+	mux.HandleFunc("GET /api/v1/acl", m.v1(m.handleV1ACLGet))
+	mux.HandleFunc("PUT /api/v1/acl", m.v1(m.handleV1ACLPut))
+	mux.HandleFunc("GET /api/v1/pac", m.v1(m.handleV1PACList))
+	mux.HandleFunc("GET /api/v1/pac/{name}", m.v1(m.handleV1PACGet))
+	mux.HandleFunc("PUT /api/v1/pac/{name}", m.v1(m.handleV1PACPut))
+	mux.HandleFunc("DELETE /api/v1/pac/{name}", m.v1(m.handleV1PACDelete))
+	mux.HandleFunc("/api/v1/", func(w http.ResponseWriter, r *http.Request) {
+		writeAPIError(w, http.StatusNotFound, codeNotFound, fmt.Sprintf("no route for %s %s", r.Method, r.URL.Path))
+	})
+}
+
+// get returns a copy of one upstream config
+func (m *Manager) get(id string) (*Upstream, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	it, ok := m.items[id]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	cp := *it.cfg
+	return &cp, nil
+}
+
+// create adds a new upstream, failing if the ID is already taken
+func (m *Manager) create(up *Upstream, start bool) (*Upstream, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if up.ID == "" {
+		up.ID = sanitizeID(up.Host, up.Port)
+	}
+	if _, ok := m.items[up.ID]; ok {
+		return nil, fmt.Errorf("%w: %s", errExists, up.ID)
+	}
+	up = m.addToPoolLocked(up)
+	var err error
+	if start {
+		err = m.startFromPoolLocked(m.items[up.ID])
+	}
+	if serr := m.saveState(); err == nil {
+		err = serr
+	}
+	return up, err
+}
+
+// handleV1List lists proxies with the same filters as /api/list
+func (m *Manager) handleV1List(w http.ResponseWriter, r *http.Request) {
+	q, err := parseListQuery(r.URL.Query())
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+	items, total, next, err := m.query(q)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"items":       items,
+		"total":       total,
+		"next_cursor": next,
+	})
+}
+
+// handleV1Create creates a proxy from a line or from fields
+// Body: {"line": "ip:port:user:pass"} or {"host": "...", "port": 8080, ...}, plus optional "start"
+func (m *Manager) handleV1Create(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Upstream
+		Line  string `json:"line"`
+		Start bool   `json:"start"`
+	}
+	if !decodeJSON(w, r, &body) {
+		return
+	}
+	up := &body.Upstream
+	if body.Line != "" {
+		parsed, err := parseProxyLine(strings.TrimSpace(body.Line))
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+			return
+		}
+		parsed.Tags, parsed.Notes, parsed.Location = up.Tags, up.Notes, up.Location
+		up = parsed
+	}
+	// runtime fields are owned by the manager
+	up.ID, up.LocalPort, up.Status, up.LastError = "", 0, "", ""
+	up.LocalUser, up.LocalPass = "", ""
+	up.Host = strings.TrimSpace(up.Host)
+	up.Tags = normalizeTags(up.Tags)
+	if up.ProxyType == "" {
+		up.ProxyType = detectProxyType(up.Host)
+	}
+	if err := validateUpstream(up); err != nil {
+		writeAPIError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+	up, err := m.create(up, body.Start)
+	if err != nil && up == nil {
+		writeManagerError(w, err)
+		return
+	}
+	if err != nil {
+		// created, but the listener did not come up
+		writeAPIError(w, http.StatusInternalServerError, codeInternal, "created but start failed: "+err.Error())
+		return
+	}
+	w.Header().Set("Location", "/api/v1/proxies/"+up.ID)
+	writeJSON(w, http.StatusCreated, up)
+}
+
+// handleV1Get returns one proxy
+func (m *Manager) handleV1Get(w http.ResponseWriter, r *http.Request) {
+	up, err := m.get(r.PathValue("id"))
+	if err != nil {
+		writeManagerError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, up)
+}
+
+// handleV1Patch edits fields of a proxy in place; omitted fields are kept
+func (m *Manager) handleV1Patch(w http.ResponseWriter, r *http.Request) {
+	var p upstreamPatch
+	if !decodeJSON(w, r, &p) {
+		return
+	}
+	up, err := m.update(r.PathValue("id"), &p)
+	if err != nil {
+		writeManagerError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, up)
+}
+
+// handleV1Delete removes a proxy
+func (m *Manager) handleV1Delete(w http.ResponseWriter, r *http.Request) {
+	if err := m.remove(r.PathValue("id")); err != nil {
+		writeManagerError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleV1Start starts a proxy and returns its config
+func (m *Manager) handleV1Start(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if err := m.start(id); err != nil {
+		var invalid *invalidError
+		if errors.Is(err, os.ErrNotExist) || errors.As(err, &invalid) {
+			writeManagerError(w, err)
+			return
+		}
+		writeAPIError(w, http.StatusInternalServerError, codeInternal, "start failed: "+err.Error())
+		return
+	}
+	m.handleV1Get(w, r)
+}
+
+// handleV1Stop stops a proxy and returns its config
+func (m *Manager) handleV1Stop(w http.ResponseWriter, r *http.Request) {
+	if err := m.stop(r.PathValue("id")); err != nil {
+		writeManagerError(w, err)
+		return
+	}
+	m.handleV1Get(w, r)
+}
+
+// handleV1ExitIP checks the exit IP of a running proxy
+func (m *Manager) handleV1ExitIP(w http.ResponseWriter, r *http.Request) {
+	m.mu.RLock()
+	it, ok := m.items[r.PathValue("id")]
+	running := ok && it.isRunning
+	var local *url.URL
+	if running {
+		local = localProxyURL(m.dialHost(), it.cfg)
+	}
+	m.mu.RUnlock()
+	if !ok {
+		writeManagerError(w, os.ErrNotExist)
+		return
+	}
+	if !running {
+		writeAPIError(w, http.StatusConflict, codeNotRunning, "proxy not running")
+		return
+	}
+	ip, err := checkProxyExitIP(local)
+	if err != nil {
+		writeAPIError(w, http.StatusBadGateway, codeUpstream, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"ip": ip})
+}
+
+// handleV1BulkAdd adds many proxy lines at once
+func (m *Manager) handleV1BulkAdd(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Lines []string `json:"lines"`
+		Text  string   `json:"text"`
+		Start bool     `json:"start"`
+	}
+	if !decodeJSON(w, r, &body) {
+		return
+	}
+	if body.Text != "" {
+		body.Lines = splitLines(body.Text)
+	}
+	if len(body.Lines) == 0 {
+		writeAPIError(w, http.StatusBadRequest, codeBadRequest, "lines required")
+		return
+	}
+	res, err := m.bulkAdd(body.Lines, body.Start)
+	if err != nil {
+		writeManagerError(w, err)
+		return
+	}
+	writeBulkResults(w, res)
+}
+
+// handleV1Bulk serves bulk start/stop/remove
+func (m *Manager) handleV1Bulk(w http.ResponseWriter, r *http.Request) {
+	ops := map[string]func(*bulkTarget) ([]BulkResult, error){
+		"start":  m.bulkStart,
+		"stop":   m.bulkStop,
+		"remove": m.bulkRemove,
+	}
+	op, ok := ops[r.PathValue("op")]
+	if !ok {
+		writeAPIError(w, http.StatusNotFound, codeNotFound, "unknown bulk operation "+r.PathValue("op"))
+		return
+	}
+	var t bulkTarget
+	if !decodeJSON(w, r, &t) {
+		return
+	}
+	res, err := op(&t)
+	if err != nil {
+		writeManagerError(w, err)
+		return
+	}
+	writeBulkResults(w, res)
+}
@@ -0,0 +1,414 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	backupDirName  = "backups"
+	backupPrefix   = "proxies-"
+	backupSuffix   = ".yaml"
+	backupTimeFmt  = "20060102-150405.000"
+	maxBackups     = 30
+	backupMinGap   = time.Minute // throttle for snapshots triggered by bursts (bulk add, clear pool)
+	backupInterval = time.Hour   // default scheduled snapshot interval
+)
+
+// BackupInfo describes a single state snapshot on disk
+type BackupInfo struct {
+	Name      string    `json:"name"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+	Size      int64     `json:"size"`
+	Items     int       `json:"items"`
+}
+
+// BackupDiff describes differences between a snapshot and the current state
+type BackupDiff struct {
+	Name    string              `json:"name"`
+	Added   []string            `json:"added"`   // in current state, not in snapshot
+	Removed []string            `json:"removed"` // in snapshot, not in current state
+	Changed map[string][]string `json:"changed"` // id -> changed fields
+}
+
+// backupDir returns the snapshot directory next to the state file
+func backupDir() string {
+	return filepath.Join(filepath.Dir(stateFile), backupDirName)
+}
+
+// snapshotLocked writes the current state into the backup directory
+// (must be called with Manager lock held). Snapshots with the same reason are
+// throttled unless force is set, so a burst of destructive calls keeps only the
+// state from before the burst. Nothing is written while the state is empty.
+func (m *Manager) snapshotLocked(reason string, force bool) (string, error) {
+	if !force {
+		if last, ok := m.lastBackup[reason]; ok && time.Since(last) < backupMinGap {
+			return "", nil
+		}
+	}
+	if len(m.items) == 0 {
+		return "", nil
+	}
+	b, err := m.marshalState()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(backupDir(), 0755); err != nil {
+		return "", err
+	}
+	now := time.Now()
+	name := backupPrefix + now.Format(backupTimeFmt) + "-" + reason + backupSuffix
+	if err := os.WriteFile(filepath.Join(backupDir(), name), b, 0600); err != nil {
+		return "", err
+	}
+	m.lastBackup[reason] = now
+	log.Printf("[Backup] snapshot %s (%d bytes)", name, len(b))
+	pruneBackups()
+	return name, nil
+}
+
+// pruneBackups removes the oldest snapshots beyond maxBackups
+func pruneBackups() {
+	names, err := backupNames()
+	if err != nil || len(names) <= maxBackups {
+		return
+	}
+	for _, name := range names[:len(names)-maxBackups] {
+		if err := os.Remove(filepath.Join(backupDir(), name)); err != nil {
+			log.Printf("[Backup] prune %s: %v", name, err)
+		}
+	}
+}
+
+// backupNames returns snapshot file names, oldest first
+func backupNames() ([]string, error) {
+	entries, err := os.ReadDir(backupDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && validBackupName(e.Name()) {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names) // timestamp prefix sorts chronologically
+	return names, nil
+}
+
+// validBackupName rejects anything that is not a plain snapshot file name
+func validBackupName(name string) bool {
+	return name == filepath.Base(name) &&
+		strings.HasPrefix(name, backupPrefix) &&
+		strings.HasSuffix(name, backupSuffix)
+}
+
+// parseBackupName extracts creation time and reason from a snapshot name
+func parseBackupName(name string) (time.Time, string) {
+	s := strings.TrimSuffix(strings.TrimPrefix(name, backupPrefix), backupSuffix)
+	if len(s) < len(backupTimeFmt) {
+		return time.Time{}, ""
+	}
+	t, _ := time.ParseInLocation(backupTimeFmt, s[:len(backupTimeFmt)], time.Local)
+	return t, strings.TrimPrefix(s[len(backupTimeFmt):], "-")
+}
+
+// readBackup loads a snapshot by name
+func readBackup(name string) (*State, int64, error) {
+	if !validBackupName(name) {
+		return nil, 0, fmt.Errorf("invalid backup name %q", name)
+	}
+	b, err := os.ReadFile(filepath.Join(backupDir(), name))
+	if err != nil {
+		return nil, 0, err
+	}
+	var st State
+	if err := yaml.Unmarshal(b, &st); err != nil {
+		return nil, 0, fmt.Errorf("parse %s: %w", name, err)
+	}
+	return &st, int64(len(b)), nil
+}
+
+// listBackups returns snapshot metadata, newest first
+func listBackups() ([]BackupInfo, error) {
+	names, err := backupNames()
+	if err != nil {
+		return nil, err
+	}
+	res := make([]BackupInfo, 0, len(names))
+	for i := len(names) - 1; i >= 0; i-- {
+		info := BackupInfo{Name: names[i]}
+		info.CreatedAt, info.Reason = parseBackupName(names[i])
+		if st, size, err := readBackup(names[i]); err == nil {
+			info.Size = size
+			info.Items = len(st.Items)
+		}
+		res = append(res, info)
+	}
+	return res, nil
+}
+
+// upstreamDiff lists the configuration fields that differ between two upstreams
+func upstreamDiff(a, b *Upstream) []string {
+	var fields []string
+	if a.Host != b.Host {
+		fields = append(fields, "host")
+	}
+	if a.Port != b.Port {
+		fields = append(fields, "port")
+	}
+	if a.Scheme != b.Scheme {
+		fields = append(fields, "scheme")
+	}
+	if a.User != b.User {
+		fields = append(fields, "user")
+	}
+	if a.Pass != b.Pass {
+		fields = append(fields, "pass")
+	}
+	if a.ProxyType != b.ProxyType {
+		fields = append(fields, "proxy_type")
+	}
+	if a.Location != b.Location {
+		fields = append(fields, "location")
+	}
+	if strings.Join(a.Tags, ",") != strings.Join(b.Tags, ",") {
+		fields = append(fields, "tags")
+	}
+	if a.Notes != b.Notes {
+		fields = append(fields, "notes")
+	}
+	if a.HealthURL != b.HealthURL {
+		fields = append(fields, "health_url")
+	}
+	if a.HealthInterval != b.HealthInterval {
+		fields = append(fields, "health_interval")
+	}
+	if a.HealthFailLimit != b.HealthFailLimit {
+		fields = append(fields, "health_fail_limit")
+	}
+	if !routesEqual(a.Routes, b.Routes) {
+		fields = append(fields, "routes")
+	}
+	if !aclEqual(a.ACL, b.ACL) {
+		fields = append(fields, "acl")
+	}
+	if a.LocalUser != b.LocalUser || a.LocalPass != b.LocalPass {
+		fields = append(fields, "local_auth")
+	}
+	if a.Provider != b.Provider || a.VendorID != b.VendorID || a.OrderID != b.OrderID {
+		fields = append(fields, "vendor")
+	}
+	if !timeEqual(a.ExpiresAt, b.ExpiresAt) {
+		fields = append(fields, "expires_at")
+	}
+	if a.SocksPort != b.SocksPort {
+		fields = append(fields, "socks_port")
+	}
+	if a.VendorStatus != b.VendorStatus {
+		fields = append(fields, "vendor_status")
+	}
+	if !timeEqual(a.MissingSince, b.MissingSince) {
+		fields = append(fields, "missing")
+	}
+	return fields
+}
+
+// diffBackup compares a snapshot with the running state
+func (m *Manager) diffBackup(name string) (*BackupDiff, error) {
+	st, _, err := readBackup(name)
+	if err != nil {
+		return nil, err
+	}
+	snap := make(map[string]*Upstream, len(st.Items))
+	for _, up := range st.Items {
+		snap[up.ID] = up
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	d := &BackupDiff{Name: name, Added: []string{}, Removed: []string{}, Changed: map[string][]string{}}
+	for id, it := range m.items {
+		old, ok := snap[id]
+		if !ok {
+			d.Added = append(d.Added, id)
+			continue
+		}
+		if fields := upstreamDiff(old, it.cfg); len(fields) > 0 {
+			d.Changed[id] = fields
+		}
+	}
+	for id := range snap {
+		if _, ok := m.items[id]; !ok {
+			d.Removed = append(d.Removed, id)
+		}
+	}
+	sort.Strings(d.Added)
+	sort.Strings(d.Removed)
+	return d, nil
+}
+
+// restoreBackup replaces the running state with a snapshot.
+// The current state is snapshotted first; proxies that were running and still
+// exist in the snapshot are started again.
+func (m *Manager) restoreBackup(name string) error {
+	st, _, err := readBackup(name)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, err := m.snapshotLocked("pre-restore", true); err != nil {
+		return fmt.Errorf("snapshot current state: %w", err)
+	}
+
+	running := make(map[string]bool)
+	for id, it := range m.items {
+		if it.isRunning {
+			running[id] = true
+			_ = m.stopLocked(it)
+		}
+	}
+
+	m.items = make(map[string]*ProxyItem, len(st.Items))
+	m.nextPort = st.Next
+	if m.nextPort < firstLocalPort {
+		m.nextPort = firstLocalPort
+	}
+	for _, up := range st.Items {
+		up.LocalPort = 0
+		up.Status = "stopped"
+		m.items[up.ID] = &ProxyItem{cfg: up}
+	}
+	m.setPACProfilesLocked(st.PACProfiles)
+	m.setRemoteListsLocked(st.RemoteLists)
+	m.setSchedulesLocked(st.Schedules)
+	m.loadGlobalACLLocked(st.ACL)
+	log.Printf("[Backup] restored %s (%d items)", name, len(st.Items))
+
+	for id := range running {
+		if it, ok := m.items[id]; ok {
+			if err := m.startFromPoolLocked(it); err != nil {
+				log.Printf("[Backup] restart %s: %v", id, err)
+			}
+		}
+	}
+	return m.saveState()
+}
+
+// backupLoop takes a scheduled snapshot every interval
+func (m *Manager) backupLoop(interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for range t.C {
+		m.mu.Lock()
+		if _, err := m.snapshotLocked("scheduled", false); err != nil {
+			log.Printf("[Backup] scheduled snapshot: %v", err)
+		}
+		m.mu.Unlock()
+	}
+}
+
+// handleBackupList lists available snapshots
+func (m *Manager) handleBackupList(w http.ResponseWriter, r *http.Request) {
+	if !m.handleAuth(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	backups, err := listBackups()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"items": backups})
+}
+
+// handleBackupCreate takes a manual snapshot
+func (m *Manager) handleBackupCreate(w http.ResponseWriter, r *http.Request) {
+	if !m.handleAuth(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	m.mu.Lock()
+	name, err := m.snapshotLocked("manual", true)
+	m.mu.Unlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if name == "" {
+		http.Error(w, "nothing to back up", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"name": name})
+}
+
+// handleBackupDiff compares a snapshot with the current state
+func (m *Manager) handleBackupDiff(w http.ResponseWriter, r *http.Request) {
+	if !m.handleAuth(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		http.Error(w, "missing name", 400)
+		return
+	}
+	d, err := m.diffBackup(name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			http.Error(w, "backup not found", 404)
+			return
+		}
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(d)
+}
+
+// handleBackupRestore restores a snapshot
+func (m *Manager) handleBackupRestore(w http.ResponseWriter, r *http.Request) {
+	if !m.handleAuth(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		http.Error(w, "missing name", 400)
+		return
+	}
+	if err := m.restoreBackup(name); err != nil {
+		if os.IsNotExist(err) {
+			http.Error(w, "backup not found", 404)
+			return
+		}
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	w.WriteHeader(204)
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+)
+
+// BulkResult is the outcome of one item in a batch operation
+type BulkResult struct {
+	ID    string    `json:"id,omitempty"`
+	Line  int       `json:"line,omitempty"` // 1-based, for bulk add
+	OK    bool      `json:"ok"`
+	Error string    `json:"error,omitempty"`
+	Item  *Upstream `json:"item,omitempty"`
+}
+
+// bulkSelector selects items by the same filters as /api/list
+type bulkSelector struct {
+	Status   string `json:"status"`
+	Type     string `json:"type"`
+	Location string `json:"location"`
+	Tag      string `json:"tag"`
+	Q        string `json:"q"`
+}
+
+// bulkTarget is the body of bulk start/stop/remove: explicit IDs and/or a selector
+type bulkTarget struct {
+	IDs      []string      `json:"ids"`
+	Selector *bulkSelector `json:"selector"`
+}
+
+var errEmptyTarget = invalidf("ids or selector required")
+
+// resolveLocked expands the target into a de-duplicated list of IDs
+// (must be called with Manager lock held). Unknown explicit IDs are kept so
+// they show up as per-item errors.
+func (t *bulkTarget) resolveLocked(m *Manager) ([]string, error) {
+	if len(t.IDs) == 0 && t.Selector == nil {
+		return nil, errEmptyTarget
+	}
+	seen := make(map[string]bool)
+	var ids []string
+	for _, id := range t.IDs {
+		if id != "" && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if t.Selector != nil {
+		v := url.Values{}
+		v.Set("status", t.Selector.Status)
+		v.Set("type", t.Selector.Type)
+		v.Set("location", t.Selector.Location)
+		v.Set("tag", t.Selector.Tag)
+		v.Set("q", t.Selector.Q)
+		q, err := parseListQuery(v)
+		if err != nil {
+			return nil, err
+		}
+		var matched []*Upstream
+		for _, it := range m.items {
+			if q.match(it.cfg) {
+				matched = append(matched, it.cfg)
+			}
+		}
+		sortUpstreams(matched, listSortKeys["local_port"], false)
+		for _, up := range matched {
+			if !seen[up.ID] {
+				seen[up.ID] = true
+				ids = append(ids, up.ID)
+			}
+		}
+	}
+	return ids, nil
+}
+
+// bulkApply runs fn for every target ID under one lock acquisition and saves
+// state once
+func (m *Manager) bulkApply(t *bulkTarget, snapshot string, fn func(it *ProxyItem) error) ([]BulkResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ids, err := t.resolveLocked(m)
+	if err != nil {
+		return nil, err
+	}
+	if snapshot != "" && len(ids) > 0 {
+		_, _ = m.snapshotLocked(snapshot, true)
+	}
+	res := make([]BulkResult, 0, len(ids))
+	for _, id := range ids {
+		r := BulkResult{ID: id}
+		it, ok := m.items[id]
+		if !ok {
+			r.Error = os.ErrNotExist.Error()
+		} else if err := fn(it); err != nil {
+			r.Error = err.Error()
+		} else {
+			r.OK = true
+		}
+		res = append(res, r)
+	}
+	return res, m.saveState()
+}
+
+// bulkStart starts all targeted proxies
+func (m *Manager) bulkStart(t *bulkTarget) ([]BulkResult, error) {
+	return m.bulkApply(t, "", m.startFromPoolLocked)
+}
+
+// bulkStop stops all targeted proxies
+func (m *Manager) bulkStop(t *bulkTarget) ([]BulkResult, error) {
+	return m.bulkApply(t, "", m.stopLocked)
+}
+
+// bulkRemove removes all targeted proxies
+func (m *Manager) bulkRemove(t *bulkTarget) ([]BulkResult, error) {
+	return m.bulkApply(t, "bulk-remove", func(it *ProxyItem) error {
+		m.removeLocked(it)
+		return nil
+	})
+}
+
+// splitLines splits text into lines for bulkAdd, keeping line numbers
+func splitLines(text string) []string {
+	return strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+}
+
+// bulkAdd parses proxy lines and adds them to the pool, or adds and starts
+// them when start is set, saving state once. Results follow input order and
+// parse errors carry their line number.
+func (m *Manager) bulkAdd(lines []string, start bool) ([]BulkResult, error) {
+	ups, errs := parseProxyLines(strings.Join(lines, "\n"))
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	res := make([]BulkResult, 0, len(ups)+len(errs))
+	for _, e := range errs {
+		res = append(res, BulkResult{Line: e.Line, Error: e.String()})
+	}
+	for _, lp := range ups {
+		r := BulkResult{Line: lp.Line}
+		var err error
+		up := lp.Up
+		if start {
+			up = m.addOrReplaceLocked(up)
+			err = m.startFromPoolLocked(m.items[up.ID])
+		} else {
+			up = m.addToPoolLocked(up)
+		}
+		r.ID, r.Item = up.ID, up
+		if err != nil {
+			r.Error = err.Error()
+		} else {
+			r.OK = true
+		}
+		res = append(res, r)
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].Line < res[j].Line })
+	return res, m.saveState()
+}
+
+// writeBulkResults writes per-item results with ok/failed counters
+func writeBulkResults(w http.ResponseWriter, res []BulkResult) {
+	failed := 0
+	for _, r := range res {
+		if !r.OK {
+			failed++
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"ok":      len(res) - failed,
+		"failed":  failed,
+		"results": res,
+	})
+}
+
+// handleBulkTarget serves bulk start/stop/remove
+// Body: {"ids": [...], "selector": {"status": "stopped", "tag": "client-a"}}
+func (m *Manager) handleBulkTarget(op func(*bulkTarget) ([]BulkResult, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !m.handleAuth(r) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var t bulkTarget
+		if err := json.NewDecoder(io.LimitReader(r.Body, 4<<20)).Decode(&t); err != nil {
+			http.Error(w, "Invalid JSON: "+err.Error(), 400)
+			return
+		}
+		res, err := op(&t)
+		if err != nil {
+			writeLegacyError(w, err)
+			return
+		}
+		writeBulkResults(w, res)
+	}
+}
+
+// writeLegacyError writes a plain-text error for the legacy /api/* endpoints:
+// validation errors are 400, the rest 500
+func writeLegacyError(w http.ResponseWriter, err error) {
+	var invalid *invalidError
+	if errors.As(err, &invalid) {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	http.Error(w, err.Error(), 500)
+}
+
+// handleBulkAdd adds many proxy lines at once
+// Body: {"lines": ["ip:port:user:pass", ...], "start": true}
+func (m *Manager) handleBulkAdd(w http.ResponseWriter, r *http.Request) {
+	if !m.handleAuth(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var body struct {
+		Lines []string `json:"lines"`
+		Text  string   `json:"text"` // alternative to lines: newline separated
+		Start bool     `json:"start"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, 4<<20)).Decode(&body); err != nil {
+		http.Error(w, "Invalid JSON: "+err.Error(), 400)
+		return
+	}
+	if body.Text != "" {
+		body.Lines = splitLines(body.Text)
+	}
+	if len(body.Lines) == 0 {
+		http.Error(w, "lines required", 400)
+		return
+	}
+	res, err := m.bulkAdd(body.Lines, body.Start)
+	if err != nil {
+		writeLegacyError(w, err)
+		return
+	}
+	writeBulkResults(w, res)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBulkErrorStatus(t *testing.T) {
+	m := newTestManager(t)
+	m.items["p1"] = &ProxyItem{cfg: &Upstream{ID: "p1", Host: "1.2.3.4", Port: 8080, Status: "stopped"}}
+	legacy := func(body string) int {
+		w := httptest.NewRecorder()
+		m.handleBulkTarget(m.bulkStop)(w, httptest.NewRequest(http.MethodPost, "/api/bulk/stop", strings.NewReader(body)))
+		return w.Code
+	}
+	v1 := func(body string) int {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/bulk/stop", strings.NewReader(body))
+		r.SetPathValue("op", "stop")
+		m.handleV1Bulk(w, r)
+		return w.Code
+	}
+
+	for name, call := range map[string]func(string) int{"legacy": legacy, "v1": v1} {
+		if code := call(`{}`); code != http.StatusBadRequest {
+			t.Errorf("%s empty target: %d, want 400", name, code)
+		}
+		if code := call(`{"selector": {"status": "stopped"}, "ids": []}`); code != http.StatusOK {
+			t.Errorf("%s valid target: %d, want 200", name, code)
+		}
+	}
+
+	// a state file in a missing directory cannot be saved
+	stateFile = filepath.Join(t.TempDir(), "gone", "proxies.yaml")
+	for name, call := range map[string]func(string) int{"legacy": legacy, "v1": v1} {
+		if code := call(`{"ids": ["p1"]}`); code != http.StatusInternalServerError {
+			t.Errorf("%s save failure: %d, want 500", name, code)
+		}
+	}
+}
+
+func TestBulkAddReportsLineNumbers(t *testing.T) {
+	m := newTestManager(t)
+	res, err := m.bulkAdd(splitLines("# pool\r\n1.2.3.4:8080\r\n\r\nnot a proxy\r\n5.6.7.8:3128:u:p"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 3 {
+		t.Fatalf("got %d results, want 3: %+v", len(res), res)
+	}
+	for i, want := range []int{2, 4, 5} {
+		if res[i].Line != want {
+			t.Errorf("result %d: line %d, want %d", i, res[i].Line, want)
+		}
+	}
+	if !res[0].OK || !res[2].OK {
+		t.Errorf("valid lines failed: %+v", res)
+	}
+	if res[1].OK || !strings.HasPrefix(res[1].Error, "line 4: ") {
+		t.Errorf("bad line: %+v, want error prefixed with line 4", res[1])
+	}
+}
@@ -1,7 +1,10 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -136,15 +139,10 @@ func parseCloudMiniProxy(item CloudMiniProxyItem) (*Upstream, error) {
 }
 
 // checkProxyExitIP checks the exit IP of a proxy by making a request through it
-func checkProxyExitIP(localPort int, token string) (string, error) {
+func checkProxyExitIP(proxyURL *url.URL) (string, error) {
 	// Use a reliable IP check service
 	checkURL := "https://api.ipify.org?format=text"
 
-	proxyURL, err := url.Parse(fmt.Sprintf("http://127.0.0.1:%d", localPort))
-	if err != nil {
-		return "", err
-	}
-
 	client := &http.Client{
 		Transport: &http.Transport{
 			Proxy: http.ProxyURL(proxyURL),
@@ -173,3 +171,184 @@ func checkProxyExitIP(localPort int, token string) (string, error) {
 
 	return ip, nil
 }
+
+// cloudMiniProvider implements Provider for the CloudMini v2 API
+type cloudMiniProvider struct {
+	baseURL string
+	client  *http.Client
+}
+
+func init() {
+	base := strings.TrimSuffix(getenv("CLOUDMINI_BASE_URL", cloudMiniBaseURL), "/")
+	registerProvider(&cloudMiniProvider{baseURL: base, client: &http.Client{Timeout: 30 * time.Second}})
+}
+
+func (c *cloudMiniProvider) Name() string { return "cloudmini" }
+
+// call sends an API request and decodes the "data" field of the
+// {"error": bool, "msg": "...", "data": ...} envelope into out
+func (c *cloudMiniProvider) call(ctx context.Context, token, method, path string, form url.Values, out any) error {
+	if token == "" {
+		return invalidf("CloudMini token required")
+	}
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Token "+token)
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("CloudMini API error: %w", err)
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 8<<20))
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("CloudMini API returned %d: %s", resp.StatusCode, strings.TrimSpace(string(b)))
+	}
+	var env struct {
+		Error bool            `json:"error"`
+		Msg   string          `json:"msg"`
+		Data  json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(b, &env); err != nil {
+		return fmt.Errorf("CloudMini: invalid response: %v", err)
+	}
+	if env.Error {
+		return fmt.Errorf("CloudMini error: %s", env.Msg)
+	}
+	if out == nil || len(env.Data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(env.Data, out); err != nil {
+		return fmt.Errorf("CloudMini: invalid data: %v", err)
+	}
+	return nil
+}
+
+func (c *cloudMiniProvider) Regions(ctx context.Context, token, proxyType string) ([]ProviderRegion, error) {
+	if proxyType == "" {
+		proxyType = "proxy-res"
+	}
+	var data []struct {
+		Type   string   `json:"type"`
+		Region []string `json:"region"`
+	}
+	if err := c.call(ctx, token, "GET", "/order_config?type="+url.QueryEscape(proxyType), nil, &data); err != nil {
+		return nil, err
+	}
+	res := make([]ProviderRegion, 0, len(data))
+	for _, d := range data {
+		res = append(res, ProviderRegion{Type: d.Type, Regions: d.Region})
+	}
+	return res, nil
+}
+
+// Order places an order for one proxy; the order call takes only type and
+// region, so order jobs place one order per proxy
+func (c *cloudMiniProvider) Order(ctx context.Context, token string, o OrderRequest) (string, error) {
+	if o.Quantity > 1 {
+		return "", invalidf("CloudMini: one proxy per order; use an order job for more")
+	}
+	form := url.Values{"type": {o.Type}, "region": {o.Region}}
+	var data []struct {
+		OrderID any `json:"order_id"` // string or number
+	}
+	if err := c.call(ctx, token, "POST", "/order", form, &data); err != nil {
+		return "", err
+	}
+	if len(data) == 0 || data[0].OrderID == nil {
+		return "", fmt.Errorf("CloudMini: order returned no order_id")
+	}
+	return fmt.Sprintf("%v", data[0].OrderID), nil
+}
+
+func (c *cloudMiniProvider) OrderStatus(ctx context.Context, token, orderID string) (OrderStatus, error) {
+	st := OrderStatus{ID: orderID}
+	var data []struct {
+		OrderStatus string `json:"order_status"`
+	}
+	if err := c.call(ctx, token, "GET", "/order?id="+url.QueryEscape(orderID), nil, &data); err != nil {
+		return st, err
+	}
+	if len(data) > 0 {
+		st.Status = data[0].OrderStatus
+	}
+	switch strings.ToLower(st.Status) {
+	case "success":
+		st.Done = true
+	case "fail", "failed", "error", "cancel", "canceled", "cancelled", "refund":
+		st.Failed = true
+	}
+	return st, nil
+}
+
+func (c *cloudMiniProvider) Proxies(ctx context.Context, token, orderID string) ([]ProviderProxy, error) {
+	path := "/proxy"
+	if orderID != "" {
+		path += "?order_id=" + url.QueryEscape(orderID)
+	}
+	var data []CloudMiniProxyFull
+	if err := c.call(ctx, token, "GET", path, nil, &data); err != nil {
+		return nil, err
+	}
+	res := make([]ProviderProxy, 0, len(data))
+	for _, item := range data {
+		res = append(res, cloudMiniProviderProxy(item))
+	}
+	return res, nil
+}
+
+// cloudMiniProviderProxy converts an API item; "ip" is "hostname:internal_id"
+// and the HTTP proxy port is in "https"
+func cloudMiniProviderProxy(item CloudMiniProxyFull) ProviderProxy {
+	hostname := item.IP
+	if i := strings.LastIndex(item.IP, ":"); i != -1 {
+		hostname = item.IP[:i]
+	}
+	port, _ := strconv.Atoi(strings.TrimSpace(item.HTTPS))
+	socks, _ := strconv.Atoi(strings.TrimSpace(item.Socks))
+	if socks < 0 || socks > 65535 {
+		socks = 0
+	}
+	p := ProviderProxy{
+		Host:      hostname,
+		Port:      port,
+		User:      item.User,
+		Pass:      item.Password,
+		ProxyType: detectProxyTypeWithPrice(hostname, item.Price),
+		Location:  item.Location,
+		Status:    item.Status,
+		SocksPort: socks,
+	}
+	if port == 0 && socks > 0 {
+		// SOCKS-only proxy: use the SOCKS port as the endpoint
+		p.Port, p.Scheme = socks, "socks5"
+	}
+	if item.PK != 0 {
+		p.VendorID = strconv.Itoa(item.PK)
+	}
+	if item.OrderID != nil {
+		p.OrderID = fmt.Sprint(item.OrderID)
+	}
+	if t, ok := parseVendorTime(item.ExpiredAt); ok {
+		p.ExpiresAt = &t
+	}
+	return p
+}
+
+// Renew and Delete have no confirmed CloudMini API call (only order_config,
+// order and proxy are used by the client); they are unsupported until one is
+func (c *cloudMiniProvider) Renew(ctx context.Context, token, vendorID string, days int) error {
+	return errUnsupported
+}
+
+func (c *cloudMiniProvider) Delete(ctx context.Context, token, vendorID string) error {
+	return errUnsupported
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// upstreamPatch is a partial update of an upstream; nil fields are kept
+type upstreamPatch struct {
+	Host            *string      `json:"host"`
+	Port            *int         `json:"port"`
+	Scheme          *string      `json:"scheme"` // "" (http) or socks5
+	User            *string      `json:"user"`
+	Pass            *string      `json:"pass"`
+	ProxyType       *string      `json:"proxy_type"`
+	Location        *string      `json:"location"`
+	Tags            *[]string    `json:"tags"`
+	Notes           *string      `json:"notes"`
+	HealthURL       *string      `json:"health_url"`
+	HealthInterval  *int         `json:"health_interval"`   // seconds, 0 = default
+	HealthFailLimit *int         `json:"health_fail_limit"` // 0 = default
+	Routes          *[]RouteRule `json:"routes"`
+	ACL             *DestACL     `json:"acl"` // {} clears the ACL
+}
+
+// apply writes the set fields onto up
+func (p *upstreamPatch) apply(up *Upstream) {
+	if p.Host != nil {
+		up.Host = strings.TrimSpace(*p.Host)
+	}
+	if p.Port != nil {
+		up.Port = *p.Port
+	}
+	if p.Scheme != nil {
+		up.Scheme = strings.ToLower(strings.TrimSpace(*p.Scheme))
+		if up.Scheme == "http" {
+			up.Scheme = ""
+		}
+	}
+	if p.User != nil {
+		up.User = *p.User
+	}
+	if p.Pass != nil {
+		up.Pass = *p.Pass
+	}
+	if p.ProxyType != nil {
+		up.ProxyType = *p.ProxyType
+	}
+	if p.Location != nil {
+		up.Location = strings.TrimSpace(*p.Location)
+	}
+	if p.Tags != nil {
+		up.Tags = normalizeTags(*p.Tags)
+	}
+	if p.Notes != nil {
+		up.Notes = strings.TrimSpace(*p.Notes)
+	}
+	if p.HealthURL != nil {
+		up.HealthURL = strings.TrimSpace(*p.HealthURL)
+	}
+	if p.HealthInterval != nil {
+		up.HealthInterval = *p.HealthInterval
+	}
+	if p.HealthFailLimit != nil {
+		up.HealthFailLimit = *p.HealthFailLimit
+	}
+	if p.Routes != nil {
+		up.Routes = normalizeRoutes(*p.Routes)
+	}
+	if p.ACL != nil {
+		up.ACL = p.ACL
+		if up.ACL.empty() {
+			up.ACL = nil
+		}
+	}
+}
+
+// validateUpstream checks host, port, type, health settings, routes and ACL of an upstream
+func validateUpstream(up *Upstream) error {
+	if strings.TrimSpace(up.Host) == "" {
+		return invalidf("host is required")
+	}
+	if strings.ContainsAny(up.Host, " /\t") {
+		return invalidf("invalid host %q", up.Host)
+	}
+	if up.Port < 1 || up.Port > 65535 {
+		return invalidf("invalid port %d", up.Port)
+	}
+	if up.Scheme != "" && up.Scheme != "socks5" {
+		return invalidf("invalid scheme %q (http or socks5)", up.Scheme)
+	}
+	switch up.ProxyType {
+	case "", "residential", "privatev4", "datacenter", "static", "unknown":
+	default:
+		return invalidf("invalid proxy_type %q", up.ProxyType)
+	}
+	if up.HealthURL != "" {
+		u, err := url.Parse(up.HealthURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return invalidf("invalid health_url %q", up.HealthURL)
+		}
+	}
+	if up.HealthInterval < 0 || up.HealthInterval > 3600 {
+		return invalidf("health_interval must be 0-3600 seconds")
+	}
+	if up.HealthFailLimit < 0 || up.HealthFailLimit > 100 {
+		return invalidf("health_fail_limit must be 0-100")
+	}
+	if _, err := compileACL(up.ACL); err != nil {
+		return invalidf("acl: %v", err)
+	}
+	return validateRoutes(up.Routes)
+}
+
+// update edits an upstream in place, keeping its ID and local port.
+// A running listener is restarted when the upstream connection or health
+// settings change.
+func (m *Manager) update(id string, p *upstreamPatch) (*Upstream, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	it, ok := m.items[id]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	next := *it.cfg
+	p.apply(&next)
+	if err := validateUpstream(&next); err != nil {
+		return nil, err
+	}
+	if next.Host != it.cfg.Host || next.Port != it.cfg.Port {
+		for oid, other := range m.items {
+			if oid != id && strings.EqualFold(other.cfg.Host, next.Host) && other.cfg.Port == next.Port {
+				return nil, fmt.Errorf("%w: %s:%d is used by %s", errExists, next.Host, next.Port, oid)
+			}
+		}
+	}
+	for i, r := range next.Routes {
+		if _, ok := m.items[r.Via]; r.Action == routeUpstream && !ok {
+			return nil, invalidf("route %d: unknown proxy %q", i+1, r.Via)
+		}
+	}
+	fields := upstreamDiff(it.cfg, &next)
+	if len(fields) == 0 {
+		return it.cfg, nil
+	}
+	_, _ = m.snapshotLocked("edit", false)
+	copyUpstreamConfig(it.cfg, &next)
+	if it.isRunning && changesUpstream(fields) {
+		if err := m.restartLocked(it); err != nil {
+			log.Printf("[proxy %s] restart after edit: %v", id, err)
+		}
+	}
+	log.Printf("[proxy %s] edited: %s", id, strings.Join(fields, ", "))
+	return it.cfg, m.saveState()
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// expiryState configures and tracks the expiry scheduler; WarnDays comes
+// from EXPIRY_WARN_DAYS and RenewDays (0 = no auto-renew) from AUTO_RENEW_DAYS
+type expiryState struct {
+	WarnDays  int
+	RenewDays int
+	warned    map[string]time.Time // id -> expiry already warned about
+	renewed   map[string]time.Time // id -> last auto-renew attempt
+	noRenew   map[string]bool      // providers without a renew call
+}
+
+// parseVendorTime reads a vendor timestamp: unix seconds or a date string
+func parseVendorTime(v any) (time.Time, bool) {
+	switch t := v.(type) {
+	case float64:
+		if t > 0 {
+			return time.Unix(int64(t), 0), true
+		}
+	case string:
+		t = strings.TrimSpace(t)
+		if n, err := strconv.ParseInt(t, 10, 64); err == nil && n > 0 {
+			return time.Unix(n, 0), true
+		}
+		for _, layout := range []string{time.RFC3339, time.DateTime, "2006-01-02T15:04:05", time.DateOnly} {
+			if ts, err := time.ParseInLocation(layout, t, time.Local); err == nil {
+				return ts, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// timeEqual compares optional timestamps
+func timeEqual(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// expiredAt reports whether up has expired at now
+func expiredAt(up *Upstream, now time.Time) bool {
+	return up.ExpiresAt != nil && !up.ExpiresAt.After(now)
+}
+
+// envDays reads a non-negative day count from the environment
+func envDays(key string, def int) int {
+	s := getenv(key, strconv.Itoa(def))
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		log.Printf("invalid %s %q, using %d", key, s, def)
+		return def
+	}
+	return n
+}
+
+// renewTarget is a proxy due for auto-renewal
+type renewTarget struct {
+	id, provider, vendorID string
+}
+
+// checkExpiry moves expired proxies out of the active set, warns about
+// proxies expiring within WarnDays and auto-renews them when enabled.
+// Providers whose Renew is unsupported are skipped after the first attempt.
+func (m *Manager) checkExpiry(now time.Time) {
+	var due []renewTarget
+	m.mu.Lock()
+	warnBefore := now.Add(time.Duration(m.expiry.WarnDays) * 24 * time.Hour)
+	changed := false
+	for id, it := range m.items {
+		up := it.cfg
+		switch {
+		case up.ExpiresAt == nil:
+			continue
+		case expiredAt(up, now):
+			if up.Status == "expired" {
+				continue
+			}
+			if err := m.stopLocked(it); err != nil {
+				log.Printf("[Expiry] stop %s: %v", id, err)
+			}
+			up.Status = "expired"
+			up.LastError = "expired at " + up.ExpiresAt.Format(time.DateTime)
+			changed = true
+			log.Printf("[Expiry] %s expired at %s, moved out of the active set", id, up.ExpiresAt.Format(time.DateTime))
+			continue
+		case up.Status == "expired":
+			// renewed since (by a sync or an edit)
+			up.Status, up.LastError = "stopped", ""
+			m.applyVendorStatusLocked(it)
+			changed = true
+			log.Printf("[Expiry] %s renewed until %s, back in the pool", id, up.ExpiresAt.Format(time.DateTime))
+		}
+		if up.ExpiresAt.After(warnBefore) {
+			continue
+		}
+		if !m.expiry.warned[id].Equal(*up.ExpiresAt) {
+			m.expiry.warned[id] = *up.ExpiresAt
+			log.Printf("[Expiry] %s expires at %s (in %s)", id, up.ExpiresAt.Format(time.DateTime), up.ExpiresAt.Sub(now).Round(time.Minute))
+		}
+		if m.expiry.RenewDays > 0 && up.Provider != "" && up.VendorID != "" && !m.expiry.noRenew[up.Provider] && now.Sub(m.expiry.renewed[id]) >= renewRetry {
+			m.expiry.renewed[id] = now
+			due = append(due, renewTarget{id: id, provider: up.Provider, vendorID: up.VendorID})
+		}
+	}
+	if changed {
+		_ = m.saveState()
+	}
+	days := m.expiry.RenewDays
+	m.mu.Unlock()
+
+	refresh := map[string]bool{}
+	for _, t := range due {
+		p, ok := getProvider(t.provider)
+		token := m.configuredToken(t.provider)
+		if !ok || token == "" {
+			log.Printf("[Expiry] auto-renew %s skipped: no %s provider token configured", t.id, t.provider)
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		err := p.Renew(ctx, token, t.vendorID, days)
+		cancel()
+		if errors.Is(err, errUnsupported) {
+			m.mu.Lock()
+			if !m.expiry.noRenew[t.provider] {
+				m.expiry.noRenew[t.provider] = true
+				log.Printf("[Expiry] %s has no renew call; renew its proxies at the vendor", t.provider)
+			}
+			m.mu.Unlock()
+			continue
+		}
+		if err != nil {
+			log.Printf("[Expiry] auto-renew %s (%s %s) failed: %v", t.id, t.provider, t.vendorID, err)
+			continue
+		}
+		log.Printf("[Expiry] renewed %s (%s %s) for %d days", t.id, t.provider, t.vendorID, days)
+		refresh[t.provider] = true
+	}
+	// pick up the new expiry dates
+	for name := range refresh {
+		if err := m.refreshFromProvider(name, m.configuredToken(name), ""); err != nil {
+			log.Printf("[Expiry] refresh %s: %v", name, err)
+		}
+	}
+}
+
+// refreshFromProvider syncs a provider's proxies (of one order, or all)
+// into the pool so vendor details such as expiry are current
+func (m *Manager) refreshFromProvider(name, token, orderID string) error {
+	p, ok := getProvider(name)
+	if !ok {
+		return fmt.Errorf("unknown provider %q", name)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	ps, err := p.Proxies(ctx, token, orderID)
+	if err != nil {
+		return err
+	}
+	m.syncIntoPool(name, ps)
+	return nil
+}
+
+// expiryLoop runs checkExpiry every tick
+func (m *Manager) expiryLoop(tick time.Duration) {
+	m.checkExpiry(time.Now())
+	t := time.NewTicker(tick)
+	defer t.Stop()
+	for now := range t.C {
+		m.checkExpiry(now)
+	}
+}
+
+// renewProxy renews a pool entry at its vendor and refreshes its expiry;
+// token "" uses the configured one
+func (m *Manager) renewProxy(ctx context.Context, id, token string, days int) error {
+	m.mu.RLock()
+	it, ok := m.items[id]
+	var provider, vendorID, orderID string
+	if ok {
+		provider, vendorID, orderID = it.cfg.Provider, it.cfg.VendorID, it.cfg.OrderID
+	}
+	m.mu.RUnlock()
+	if !ok {
+		return os.ErrNotExist
+	}
+	if provider == "" || vendorID == "" {
+		return invalidf("%s has no vendor ID; sync it from its provider first", id)
+	}
+	p, ok := getProvider(provider)
+	if !ok {
+		return invalidf("unknown provider %q", provider)
+	}
+	if token == "" {
+		token = m.configuredToken(provider)
+	}
+	if err := p.Renew(ctx, token, vendorID, days); err != nil {
+		return err
+	}
+	log.Printf("[Expiry] renewed %s (%s %s) for %d days", id, provider, vendorID, days)
+	return m.refreshFromProvider(provider, token, orderID)
+}
+
+// expiringItem is a pool entry with an expiry date
+type expiringItem struct {
+	ID        string    `json:"id"`
+	Provider  string    `json:"provider,omitempty"`
+	VendorID  string    `json:"vendor_id,omitempty"`
+	OrderID   string    `json:"order_id,omitempty"`
+	Status    string    `json:"status"`
+	ExpiresAt time.Time `json:"expires_at"`
+	DaysLeft  int       `json:"days_left"` // rounded down, negative once expired
+}
+
+// handleV1Expiring lists proxies expiring within ?days= (default
+// EXPIRY_WARN_DAYS), expired ones included, soonest first
+func (m *Manager) handleV1Expiring(w http.ResponseWriter, r *http.Request) {
+	m.mu.RLock()
+	days, renewDays := m.expiry.WarnDays, m.expiry.RenewDays
+	m.mu.RUnlock()
+	if s := r.URL.Query().Get("days"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			writeAPIError(w, http.StatusBadRequest, codeBadRequest, "invalid days")
+			return
+		}
+		days = n
+	}
+	now := time.Now()
+	before := now.Add(time.Duration(days) * 24 * time.Hour)
+	items := []expiringItem{}
+	m.mu.RLock()
+	for _, it := range m.items {
+		up := it.cfg
+		if up.ExpiresAt == nil || up.ExpiresAt.After(before) {
+			continue
+		}
+		items = append(items, expiringItem{
+			ID: up.ID, Provider: up.Provider, VendorID: up.VendorID, OrderID: up.OrderID, Status: up.Status,
+			ExpiresAt: *up.ExpiresAt,
+			DaysLeft:  int(math.Floor(up.ExpiresAt.Sub(now).Hours() / 24)),
+		})
+	}
+	m.mu.RUnlock()
+	sort.Slice(items, func(a, b int) bool { return items[a].ExpiresAt.Before(items[b].ExpiresAt) })
+	writeJSON(w, http.StatusOK, map[string]any{"days": days, "auto_renew_days": renewDays, "items": items})
+}
+
+// handleV1Renew renews a proxy at its vendor; ?days= defaults to 30 and the
+// X-Provider-Token header overrides the configured token
+func (m *Manager) handleV1Renew(w http.ResponseWriter, r *http.Request) {
+	days := 30
+	if s := r.URL.Query().Get("days"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			writeAPIError(w, http.StatusBadRequest, codeBadRequest, "invalid days")
+			return
+		}
+		days = n
+	}
+	err := m.renewProxy(r.Context(), r.PathValue("id"), r.Header.Get("X-Provider-Token"), days)
+	var invalid *invalidError
+	switch {
+	case err == nil:
+		m.handleV1Get(w, r)
+	case errors.Is(err, os.ErrNotExist) || errors.As(err, &invalid):
+		writeManagerError(w, err)
+	default:
+		writeProviderError(w, err)
+	}
+}
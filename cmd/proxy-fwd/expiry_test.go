@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAutoRenewSkipsUnsupportedProvider(t *testing.T) {
+	m := newTestManager(t)
+	m.expiry.RenewDays = 30
+	// remote lists have no renew call
+	registerProvider(newRemoteListProvider(RemoteList{Name: "test-renew", URL: "https://vendor.example/list"}))
+	defer unregisterProvider("test-renew")
+	if err := m.secrets.set("test-renew", "tok"); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	expires := now.Add(24 * time.Hour)
+	m.items["p1"] = &ProxyItem{cfg: &Upstream{ID: "p1", Host: "1.2.3.4", Port: 8080, Status: "stopped",
+		Provider: "test-renew", VendorID: "v1", ExpiresAt: &expires}}
+
+	m.checkExpiry(now)
+	if !m.expiry.noRenew["test-renew"] {
+		t.Fatal("unsupported renew not remembered")
+	}
+	m.checkExpiry(now.Add(renewRetry))
+	if got := m.expiry.renewed["p1"]; !got.Equal(now) {
+		t.Errorf("renew retried for an unsupported provider at %v", got)
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// exportProxy is one running local listener with the metadata of its upstream
+type exportProxy struct {
+	Host string // listener host as seen by clients (127.0.0.1 unless LAN mode)
+	Addr string // host:port
+	Port int
+	Up   Upstream // Up.LocalUser/LocalPass are the listener credentials
+}
+
+// exportFormat renders a list of local listeners
+type exportFormat struct {
+	ContentType string
+	Filename    string // set for formats meant to be saved as a file
+	Render      func(ps []exportProxy) ([]byte, error)
+}
+
+// exportFormats are the formats accepted by /api/export-local?format=
+var exportFormats = map[string]exportFormat{
+	"plain":     {"text/plain; charset=utf-8", "", exportPlain},
+	"url":       {"text/plain; charset=utf-8", "", exportURL},
+	"csv":       {"text/csv; charset=utf-8", "proxies.csv", exportCSV},
+	"pac":       {"application/x-ns-proxy-autoconfig", "proxy.pac", exportPAC},
+	"proxifier": {"application/xml", "proxy-fwd.ppx", exportProxifier},
+	"chrome":    {"text/plain; charset=utf-8", "", exportChrome},
+	"clash":     {"application/yaml", "clash.yaml", exportClash},
+}
+
+// exportable returns the running listeners matching q, ordered by local port
+func (m *Manager) exportable(q listQuery) []exportProxy {
+	m.mu.RLock()
+	var ups []*Upstream
+	for _, it := range m.items {
+		if it.isRunning && it.cfg.LocalPort > 0 && q.match(it.cfg) {
+			cp := *it.cfg
+			ups = append(ups, &cp)
+		}
+	}
+	m.mu.RUnlock()
+	sortUpstreams(ups, listSortKeys["local_port"], false)
+	res := make([]exportProxy, 0, len(ups))
+	for _, up := range ups {
+		host := m.clientHost()
+		res = append(res, exportProxy{Host: host, Addr: net.JoinHostPort(host, strconv.Itoa(up.LocalPort)), Port: up.LocalPort, Up: *up})
+	}
+	return res
+}
+
+func exportPlain(ps []exportProxy) ([]byte, error) {
+	var b bytes.Buffer
+	for _, p := range ps {
+		if p.Up.LocalUser != "" {
+			b.WriteString(p.Addr + ":" + p.Up.LocalUser + ":" + p.Up.LocalPass + "\n")
+		} else {
+			b.WriteString(p.Addr + "\n")
+		}
+	}
+	return b.Bytes(), nil
+}
+
+func exportURL(ps []exportProxy) ([]byte, error) {
+	var b bytes.Buffer
+	for _, p := range ps {
+		b.WriteString(localProxyURL(p.Host, &p.Up).String() + "\n")
+	}
+	return b.Bytes(), nil
+}
+
+func exportCSV(ps []exportProxy) ([]byte, error) {
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+	w.Write([]string{"local", "id", "upstream", "scheme", "proxy_type", "location", "tags", "notes", "status", "local_user", "local_pass"})
+	for _, p := range ps {
+		scheme := p.Up.Scheme
+		if scheme == "" {
+			scheme = "http"
+		}
+		w.Write([]string{
+			p.Addr, p.Up.ID, fmt.Sprintf("%s:%d", p.Up.Host, p.Up.Port), scheme,
+			p.Up.ProxyType, p.Up.Location, strings.Join(p.Up.Tags, ","), p.Up.Notes, p.Up.Status,
+			p.Up.LocalUser, p.Up.LocalPass,
+		})
+	}
+	w.Flush()
+	return b.Bytes(), w.Error()
+}
+
+// exportPAC routes everything through the listeners in order (browsers fail
+// over to the next entry), falling back to DIRECT only when none are running.
+// PAC cannot carry credentials; browsers prompt for them.
+func exportPAC(ps []exportProxy) ([]byte, error) {
+	route := "DIRECT"
+	if len(ps) > 0 {
+		parts := make([]string, len(ps))
+		for i, p := range ps {
+			parts[i] = "PROXY " + p.Addr
+		}
+		route = strings.Join(parts, "; ")
+	}
+	return []byte("function FindProxyForURL(url, host) {\n" +
+		"  if (isPlainHostName(host) || host === \"127.0.0.1\" || host === \"localhost\") return \"DIRECT\";\n" +
+		"  return " + strconv.Quote(route) + ";\n" +
+		"}\n"), nil
+}
+
+// proxifierExport is a minimal Proxifier .ppx profile listing the listeners
+type proxifierExport struct {
+	XMLName  xml.Name              `xml:"ProxifierProfile"`
+	Version  string                `xml:"version,attr"`
+	Platform string                `xml:"platform,attr"`
+	Proxies  []proxifierExportItem `xml:"ProxyList>Proxy"`
+}
+
+type proxifierExportItem struct {
+	ID      int                      `xml:"id,attr"`
+	Type    string                   `xml:"type,attr"`
+	Label   string                   `xml:"Label,omitempty"`
+	Address string                   `xml:"Address"`
+	Port    int                      `xml:"Port"`
+	Options int                      `xml:"Options"`
+	Auth    *proxifierExportAuthItem `xml:"Authentication,omitempty"`
+}
+
+type proxifierExportAuthItem struct {
+	Enabled  bool   `xml:"enabled,attr"`
+	Username string `xml:"Username"`
+	Password string `xml:"Password"`
+}
+
+func exportProxifier(ps []exportProxy) ([]byte, error) {
+	doc := proxifierExport{Version: "102", Platform: "Windows"}
+	for i, p := range ps {
+		item := proxifierExportItem{
+			ID: 100 + i, Type: "HTTPS", Label: p.Up.ID, Address: p.Host, Port: p.Port, Options: 48,
+		}
+		if p.Up.LocalUser != "" {
+			item.Auth = &proxifierExportAuthItem{Enabled: true, Username: p.Up.LocalUser, Password: p.Up.LocalPass}
+		}
+		doc.Proxies = append(doc.Proxies, item)
+	}
+	b, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(b, '\n')...), nil
+}
+
+// exportChrome prints one launch argument line per listener; each profile
+// needs its own --user-data-dir so Chrome does not reuse a running instance.
+// Chrome ignores credentials in --proxy-server and prompts instead.
+func exportChrome(ps []exportProxy) ([]byte, error) {
+	var b bytes.Buffer
+	for _, p := range ps {
+		fmt.Fprintf(&b, "--proxy-server=\"http://%s\" --user-data-dir=\"%%LOCALAPPDATA%%\\proxy-fwd\\%s\"\n", p.Addr, p.Up.ID)
+	}
+	return b.Bytes(), nil
+}
+
+func exportClash(ps []exportProxy) ([]byte, error) {
+	type clashProxy struct {
+		Name     string `yaml:"name"`
+		Type     string `yaml:"type"`
+		Server   string `yaml:"server"`
+		Port     int    `yaml:"port"`
+		Username string `yaml:"username,omitempty"`
+		Password string `yaml:"password,omitempty"`
+	}
+	type clashGroup struct {
+		Name    string   `yaml:"name"`
+		Type    string   `yaml:"type"`
+		Proxies []string `yaml:"proxies"`
+	}
+	var doc struct {
+		Proxies     []clashProxy `yaml:"proxies"`
+		ProxyGroups []clashGroup `yaml:"proxy-groups"`
+	}
+	group := clashGroup{Name: "proxy-fwd", Type: "select"}
+	for _, p := range ps {
+		doc.Proxies = append(doc.Proxies, clashProxy{
+			Name: p.Up.ID, Type: "http", Server: p.Host, Port: p.Port,
+			Username: p.Up.LocalUser, Password: p.Up.LocalPass,
+		})
+		group.Proxies = append(group.Proxies, p.Up.ID)
+	}
+	if len(group.Proxies) > 0 {
+		doc.ProxyGroups = []clashGroup{group}
+	}
+	return yaml.Marshal(doc)
+}
+
+// handleExportLocal exports running local listeners
+// Query: ?format=plain|url|csv|pac|proxifier|chrome|clash plus the /api/list filters (tag, type, ...)
+func (m *Manager) handleExportLocal(w http.ResponseWriter, r *http.Request) {
+	if !m.handleAuth(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	name := r.URL.Query().Get("format")
+	if name == "" {
+		name = "plain"
+	}
+	f, ok := exportFormats[name]
+	if !ok {
+		http.Error(w, "unknown format "+name, 400)
+		return
+	}
+	q, err := parseListQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	b, err := f.Render(m.exportable(q))
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	w.Header().Set("Content-Type", f.ContentType)
+	if f.Filename != "" && r.URL.Query().Get("download") == "1" {
+		w.Header().Set("Content-Disposition", "attachment; filename="+f.Filename)
+	}
+	w.Write(b)
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"log"
+	"net/netip"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -120,6 +121,34 @@ func createLocalhostAllowRule(browserPath string) error {
 	return err
 }
 
+// createLANInboundRule lets allowlisted LAN clients reach the proxy ports
+// (LAN mode); the rule is recreated so allowlist changes apply
+func createLANInboundRule(allow []netip.Prefix) error {
+	if !isAdmin() {
+		return nil
+	}
+	exePath, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("cannot get executable path: %w", err)
+	}
+	ruleName := "ProxyFwd Allow LAN In"
+	remote := make([]string, len(allow))
+	for i, p := range allow {
+		remote[i] = p.String()
+	}
+
+	cmd := exec.Command("powershell", "-NoProfile", "-Command",
+		fmt.Sprintf(`Remove-NetFirewallRule -DisplayName "%s" -ErrorAction SilentlyContinue; New-NetFirewallRule -DisplayName "%s" -Direction Inbound -Program "%s" -Action Allow -Profile Any -Protocol TCP -LocalPort %s -RemoteAddress %s -Group "%s" -ErrorAction SilentlyContinue`,
+			ruleName, ruleName, exePath, portRange, strings.Join(remote, ","), firewallGroup))
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%w, output: %s", err, output)
+	}
+	log.Printf("[Firewall] ✅ LAN inbound rule created for %s", strings.Join(remote, ", "))
+	return nil
+}
+
 // createBlockRule blocks browser from accessing internet directly
 func createBlockRule(browserPath string) error {
 	browserName := filepath.Base(browserPath)
@@ -3,10 +3,9 @@ package main
 import (
 	"crypto/subtle"
 	"encoding/json"
-	"fmt"
 	"io"
 	"net/http"
-	"strings"
+	"net/url"
 )
 
 // handleAuth checks admin token authorization
@@ -26,7 +25,7 @@ func (m *Manager) handleAuth(r *http.Request) bool {
 
 // ui returns the HTTP handler for web UI and API
 func (m *Manager) ui() http.Handler {
-	mux := http.NewServeMux()
+	mux := newRouter()
 
 	// Web UI
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
@@ -38,15 +37,34 @@ func (m *Manager) ui() http.Handler {
 		io.WriteString(w, indexHTML)
 	})
 
+	// Versioned API (resource-oriented, JSON errors)
+	m.registerV1(mux)
+	m.registerProviders(mux)
+
+	// Legacy API below is kept as a compatibility shim for the UI and
+	// existing scripts; new clients should use /api/v1.
+
 	// API: List all proxies
 	mux.HandleFunc("/api/list", func(w http.ResponseWriter, r *http.Request) {
 		if !m.handleAuth(r) {
 			http.Error(w, "unauthorized", http.StatusUnauthorized)
 			return
 		}
+		q, err := parseListQuery(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		items, total, next, err := m.query(q)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
 		json.NewEncoder(w).Encode(struct {
-			Items []*Upstream `json:"items"`
-		}{Items: m.list()})
+			Items      []*Upstream `json:"items"`
+			Total      int         `json:"total"`
+			NextCursor string      `json:"next_cursor,omitempty"`
+		}{Items: items, Total: total, NextCursor: next})
 	})
 
 	// API: Add proxy and auto-start
@@ -56,12 +74,7 @@ func (m *Manager) ui() http.Handler {
 			return
 		}
 		b, _ := io.ReadAll(io.LimitReader(r.Body, 1<<20))
-		line := strings.TrimSpace(string(b))
-		if line == "" {
-			http.Error(w, "body must be 'ip:port:user:pass' or 'ip:port'", 400)
-			return
-		}
-		up, err := parseProxyLine(line)
+		up, err := parseOneProxy(string(b))
 		if err != nil {
 			http.Error(w, err.Error(), 400)
 			return
@@ -82,12 +95,7 @@ func (m *Manager) ui() http.Handler {
 			return
 		}
 		b, _ := io.ReadAll(io.LimitReader(r.Body, 1<<20))
-		line := strings.TrimSpace(string(b))
-		if line == "" {
-			http.Error(w, "body must be 'ip:port:user:pass' or 'ip:port'", 400)
-			return
-		}
-		up, err := parseProxyLine(line)
+		up, err := parseOneProxy(string(b))
 		if err != nil {
 			http.Error(w, err.Error(), 400)
 			return
@@ -155,17 +163,10 @@ func (m *Manager) ui() http.Handler {
 	})
 
 	// API: Export local proxy addresses
-	mux.HandleFunc("/api/export-local", func(w http.ResponseWriter, r *http.Request) {
-		if !m.handleAuth(r) {
-			http.Error(w, "unauthorized", http.StatusUnauthorized)
-			return
-		}
-		var lines []string
-		for _, it := range m.list() {
-			lines = append(lines, fmt.Sprintf("127.0.0.1:%d", it.LocalPort))
-		}
-		io.WriteString(w, strings.Join(lines, "\n"))
-	})
+	mux.HandleFunc("/api/export-local", m.handleExportLocal)
+
+	// PAC scripts for browser profiles (rules managed under /api/v1/pac)
+	mux.HandleFunc("GET /pac/{file}", m.handlePACScript)
 
 	// API: CloudMini regions proxy
 	mux.HandleFunc("/api/cloudmini/regions", m.handleCloudMiniRegions)
@@ -176,6 +177,27 @@ func (m *Manager) ui() http.Handler {
 	// API: CloudMini sync all proxy-res to pool
 	mux.HandleFunc("/api/cloudmini/sync", m.handleCloudMiniSync)
 
+	// API: Tags and notes
+	mux.HandleFunc("/api/tags", m.handleSetLabels)
+	mux.HandleFunc("/api/tag/start", m.handleTagAction(m.bulkStart))
+	mux.HandleFunc("/api/tag/stop", m.handleTagAction(m.bulkStop))
+	mux.HandleFunc("/api/tag/remove", m.handleTagAction(m.bulkRemove))
+
+	// API: Batch operations (one lock, one state save, per-item results)
+	mux.HandleFunc("/api/bulk/add", m.handleBulkAdd)
+	mux.HandleFunc("/api/bulk/start", m.handleBulkTarget(m.bulkStart))
+	mux.HandleFunc("/api/bulk/stop", m.handleBulkTarget(m.bulkStop))
+	mux.HandleFunc("/api/bulk/remove", m.handleBulkTarget(m.bulkRemove))
+
+	// API: Import files (CSV, JSON, Proxifier, SwitchyOmega, Clash, sing-box)
+	mux.HandleFunc("/api/import", m.handleImport)
+
+	// API: State backups
+	mux.HandleFunc("/api/backups", m.handleBackupList)
+	mux.HandleFunc("/api/backups/create", m.handleBackupCreate)
+	mux.HandleFunc("/api/backups/diff", m.handleBackupDiff)
+	mux.HandleFunc("/api/backups/restore", m.handleBackupRestore)
+
 	// API: Firewall status
 	mux.HandleFunc("/api/firewall/status", func(w http.ResponseWriter, r *http.Request) {
 		if !m.handleAuth(r) {
@@ -205,6 +227,10 @@ func (m *Manager) ui() http.Handler {
 
 		m.mu.RLock()
 		it, ok := m.items[id]
+		var local *url.URL
+		if ok && it.isRunning {
+			local = localProxyURL(m.dialHost(), it.cfg)
+		}
 		m.mu.RUnlock()
 
 		if !ok {
@@ -212,12 +238,12 @@ func (m *Manager) ui() http.Handler {
 			return
 		}
 
-		if !it.isRunning {
+		if local == nil {
 			http.Error(w, "proxy not running", 400)
 			return
 		}
 
-		ip, err := checkProxyExitIP(it.cfg.LocalPort, m.adminToken)
+		ip, err := checkProxyExitIP(local)
 		if err != nil {
 			http.Error(w, err.Error(), 500)
 			return
@@ -228,5 +254,8 @@ func (m *Manager) ui() http.Handler {
 		})
 	})
 
+	// API: OpenAPI document for client generators
+	mux.HandleFunc("/api/openapi.json", m.handleOpenAPI)
+
 	return mux
 }
@@ -0,0 +1,701 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Import formats accepted by /api/import and `proxy-fwd import`
+const (
+	importAuto         = "auto"
+	importLines        = "lines"        // one proxy line per row (see importer.go)
+	importCSV          = "csv"          // header row with host,port[,user,pass,scheme,tags,notes,location]
+	importJSON         = "json"         // array of proxy objects or lines, or {"items": [...]}
+	importProxifier    = "proxifier"    // Proxifier .ppx profile (XML)
+	importSwitchyOmega = "switchyomega" // SwitchyOmega options backup (.bak JSON)
+	importClash        = "clash"        // Clash / Clash.Meta config, "proxies:" list
+	importSingBox      = "singbox"      // sing-box config, "outbounds" list
+)
+
+var importFormats = []string{importAuto, importLines, importCSV, importJSON, importProxifier, importSwitchyOmega, importClash, importSingBox}
+
+const maxImportSize = 16 << 20
+
+// importEntry is one proxy found in an import file
+type importEntry struct {
+	Source string // where in the file: "line 3", "proxies[2] hk-01", ...
+	Up     *Upstream
+	Err    string // parse error
+	Skip   string // reason the entry cannot be imported (unsupported protocol)
+}
+
+// ImportResult describes what an import did (or would do) with one entry
+type ImportResult struct {
+	Source  string    `json:"source"`
+	ID      string    `json:"id,omitempty"`
+	Action  string    `json:"action"` // add, update, skip, error
+	Changes []string  `json:"changes,omitempty"`
+	Reason  string    `json:"reason,omitempty"`
+	Item    *Upstream `json:"item,omitempty"`
+}
+
+// ImportReport is the outcome of an import
+type ImportReport struct {
+	Format  string         `json:"format"`
+	DryRun  bool           `json:"dry_run"`
+	Added   int            `json:"added"`
+	Updated int            `json:"updated"`
+	Skipped int            `json:"skipped"`
+	Failed  int            `json:"failed"`
+	Results []ImportResult `json:"results"`
+}
+
+// detectImportFormat guesses the format from the file name and content
+func detectImportFormat(name string, data []byte) string {
+	ext := strings.ToLower(filepath.Ext(name))
+	switch ext {
+	case ".ppx":
+		return importProxifier
+	case ".csv":
+		return importCSV
+	case ".bak":
+		return importSwitchyOmega
+	}
+	s := bytes.TrimSpace(data)
+	if len(s) == 0 {
+		return importLines
+	}
+	switch s[0] {
+	case '<':
+		return importProxifier
+	case '[':
+		return importJSON
+	case '{':
+		var obj map[string]json.RawMessage
+		if json.Unmarshal(s, &obj) != nil {
+			return importJSON
+		}
+		if _, ok := obj["outbounds"]; ok {
+			return importSingBox
+		}
+		if _, ok := obj["proxies"]; ok {
+			return importClash
+		}
+		for k := range obj {
+			if strings.HasPrefix(k, "+") {
+				return importSwitchyOmega
+			}
+		}
+		return importJSON
+	}
+	if ext == ".yaml" || ext == ".yml" || bytes.Contains(s, []byte("proxies:")) {
+		return importClash
+	}
+	first, _, _ := bytes.Cut(s, []byte("\n"))
+	if bytes.Contains(first, []byte(",")) {
+		return importCSV
+	}
+	return importLines
+}
+
+// parseImport decodes data in the given format into import entries
+func parseImport(format string, data []byte) ([]importEntry, error) {
+	switch format {
+	case importLines:
+		return parseImportLines(data), nil
+	case importCSV:
+		return parseImportCSV(data)
+	case importJSON:
+		return parseImportJSON(data)
+	case importProxifier:
+		return parseImportProxifier(data)
+	case importSwitchyOmega:
+		return parseImportSwitchyOmega(data)
+	case importClash:
+		return parseImportClash(data)
+	case importSingBox:
+		return parseImportSingBox(data)
+	}
+	return nil, invalidf("unknown format %q (want one of %s)", format, strings.Join(importFormats, ", "))
+}
+
+// newImportEntry builds an entry from connection fields, validating them;
+// an empty scheme means http
+func newImportEntry(source, scheme, host string, port int, user, pass string) importEntry {
+	if scheme == "" {
+		scheme = "http"
+	}
+	s, ok := upstreamSchemes[strings.ToLower(scheme)]
+	if !ok {
+		return importEntry{Source: source, Skip: fmt.Sprintf("unsupported proxy type %q", scheme)}
+	}
+	up := &Upstream{Host: strings.TrimSpace(host), Port: port, Scheme: s, User: user, Pass: pass}
+	if err := validateUpstream(up); err != nil {
+		return importEntry{Source: source, Err: err.Error()}
+	}
+	up.ID = sanitizeID(up.Host, up.Port)
+	up.ProxyType = detectProxyType(up.Host)
+	return importEntry{Source: source, Up: up}
+}
+
+func parseImportLines(data []byte) []importEntry {
+	var res []importEntry
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		e := importEntry{Source: "line " + strconv.Itoa(i+1)}
+		if up, err := parseProxyLine(line); err != nil {
+			e.Err = err.Error()
+		} else {
+			e.Up = up
+		}
+		res = append(res, e)
+	}
+	return res
+}
+
+// csvColumns maps accepted CSV header names to field names
+var csvColumns = map[string]string{
+	"host": "host", "ip": "host", "server": "host", "address": "host",
+	"port": "port",
+	"user": "user", "username": "user", "login": "user",
+	"pass": "pass", "password": "pass",
+	"scheme": "scheme", "type": "scheme", "protocol": "scheme",
+	"tags": "tags", "notes": "notes", "location": "location",
+}
+
+func parseImportCSV(data []byte) ([]importEntry, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	r.Comment = '#'
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, invalidf("csv: %v", err)
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	// header row is optional; without one columns are host,port,user,pass
+	cols := []string{"host", "port", "user", "pass"}
+	if _, ok := csvColumns[strings.ToLower(strings.TrimSpace(rows[0][0]))]; ok {
+		cols = make([]string, len(rows[0]))
+		for i, h := range rows[0] {
+			cols[i] = csvColumns[strings.ToLower(strings.TrimSpace(h))]
+		}
+		rows = rows[1:]
+	}
+	var res []importEntry
+	for i, row := range rows {
+		f := map[string]string{}
+		for j, v := range row {
+			if j < len(cols) && cols[j] != "" {
+				f[cols[j]] = strings.TrimSpace(v)
+			}
+		}
+		source := "row " + strconv.Itoa(i+1)
+		port, err := strconv.Atoi(f["port"])
+		if err != nil {
+			res = append(res, importEntry{Source: source, Err: fmt.Sprintf("invalid port %q", f["port"])})
+			continue
+		}
+		e := newImportEntry(source, f["scheme"], f["host"], port, f["user"], f["pass"])
+		if e.Up != nil {
+			e.Up.Tags = normalizeTags(strings.FieldsFunc(f["tags"], func(r rune) bool { return r == ',' || r == ';' || r == '|' }))
+			e.Up.Notes = f["notes"]
+			e.Up.Location = f["location"]
+		}
+		res = append(res, e)
+	}
+	return res, nil
+}
+
+// jsonProxy accepts our own Upstream JSON plus common aliases
+type jsonProxy struct {
+	Host      string   `json:"host"`
+	Server    string   `json:"server"`
+	IP        string   `json:"ip"`
+	Port      any      `json:"port"`
+	User      string   `json:"user"`
+	Username  string   `json:"username"`
+	Pass      string   `json:"pass"`
+	Password  string   `json:"password"`
+	Scheme    string   `json:"scheme"`
+	Type      string   `json:"type"`
+	Tags      []string `json:"tags"`
+	Notes     string   `json:"notes"`
+	Location  string   `json:"location"`
+	ProxyType string   `json:"proxy_type"`
+}
+
+// anyPort converts a JSON/YAML port (number or string) to int
+func anyPort(v any) (int, error) {
+	switch p := v.(type) {
+	case float64:
+		return int(p), nil
+	case int:
+		return p, nil
+	case string:
+		return strconv.Atoi(strings.TrimSpace(p))
+	}
+	return 0, fmt.Errorf("invalid port %v", v)
+}
+
+func parseImportJSON(data []byte) ([]importEntry, error) {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		// {"items": [...]} as returned by /api/list, or {"proxies": [...]}
+		var obj struct {
+			Items   []json.RawMessage `json:"items"`
+			Proxies []json.RawMessage `json:"proxies"`
+		}
+		if err2 := json.Unmarshal(data, &obj); err2 != nil {
+			return nil, invalidf("json: %v", err)
+		}
+		raw = append(obj.Items, obj.Proxies...)
+	}
+	var res []importEntry
+	for i, msg := range raw {
+		source := fmt.Sprintf("[%d]", i)
+		var line string
+		if json.Unmarshal(msg, &line) == nil {
+			e := importEntry{Source: source}
+			if up, err := parseProxyLine(line); err != nil {
+				e.Err = err.Error()
+			} else {
+				e.Up = up
+			}
+			res = append(res, e)
+			continue
+		}
+		var p jsonProxy
+		if err := json.Unmarshal(msg, &p); err != nil {
+			res = append(res, importEntry{Source: source, Err: err.Error()})
+			continue
+		}
+		port, err := anyPort(p.Port)
+		if err != nil {
+			res = append(res, importEntry{Source: source, Err: err.Error()})
+			continue
+		}
+		scheme := p.Scheme
+		if scheme == "" && p.Type != "" {
+			scheme = p.Type
+		}
+		e := newImportEntry(source, scheme, firstNonEmpty(p.Host, p.Server, p.IP), port,
+			firstNonEmpty(p.User, p.Username), firstNonEmpty(p.Pass, p.Password))
+		if e.Up != nil {
+			e.Up.Tags = normalizeTags(p.Tags)
+			e.Up.Notes = p.Notes
+			e.Up.Location = p.Location
+			if p.ProxyType != "" {
+				e.Up.ProxyType = p.ProxyType
+			}
+		}
+		res = append(res, e)
+	}
+	return res, nil
+}
+
+// firstNonEmpty returns the first non-empty string
+func firstNonEmpty(vals ...string) string {
+	for _, v := range vals {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// proxifierProfile is the subset of a Proxifier .ppx profile we read
+type proxifierProfile struct {
+	Proxies []struct {
+		ID      string `xml:"id,attr"`
+		Type    string `xml:"type,attr"`
+		Address string `xml:"Address"`
+		Port    int    `xml:"Port"`
+		Auth    struct {
+			Enabled  bool   `xml:"enabled,attr"`
+			Username string `xml:"Username"`
+			Password string `xml:"Password"`
+		} `xml:"Authentication"`
+	} `xml:"ProxyList>Proxy"`
+}
+
+// proxifierSchemes maps Proxifier proxy types to URL schemes
+var proxifierSchemes = map[string]string{"HTTP": "http", "HTTPS": "http", "SOCKS5": "socks5"}
+
+func parseImportProxifier(data []byte) ([]importEntry, error) {
+	var p proxifierProfile
+	if err := xml.Unmarshal(data, &p); err != nil {
+		return nil, invalidf("proxifier: %v", err)
+	}
+	var res []importEntry
+	for _, px := range p.Proxies {
+		source := "proxy id=" + px.ID
+		scheme, ok := proxifierSchemes[strings.ToUpper(px.Type)]
+		if !ok {
+			res = append(res, importEntry{Source: source, Skip: fmt.Sprintf("unsupported proxy type %q", px.Type)})
+			continue
+		}
+		user, pass := "", ""
+		if px.Auth.Enabled {
+			user, pass = px.Auth.Username, px.Auth.Password
+		}
+		res = append(res, newImportEntry(source, scheme, px.Address, px.Port, user, pass))
+	}
+	return res, nil
+}
+
+// omegaServer is a SwitchyOmega proxy server entry
+type omegaServer struct {
+	Scheme string `json:"scheme"`
+	Host   string `json:"host"`
+	Port   int    `json:"port"`
+}
+
+// omegaProfile is the subset of a SwitchyOmega FixedProfile we read
+type omegaProfile struct {
+	Name          string                  `json:"name"`
+	ProfileType   string                  `json:"profileType"`
+	FallbackProxy *omegaServer            `json:"fallbackProxy"`
+	ProxyForHTTP  *omegaServer            `json:"proxyForHttp"`
+	ProxyForHTTPS *omegaServer            `json:"proxyForHttps"`
+	Auth          map[string]omegaAccount `json:"auth"`
+}
+
+type omegaAccount struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+func parseImportSwitchyOmega(data []byte) ([]importEntry, error) {
+	var opts map[string]json.RawMessage
+	if err := json.Unmarshal(data, &opts); err != nil {
+		return nil, invalidf("switchyomega: %v", err)
+	}
+	keys := make([]string, 0, len(opts))
+	for k := range opts {
+		if strings.HasPrefix(k, "+") {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	var res []importEntry
+	for _, k := range keys {
+		var p omegaProfile
+		if json.Unmarshal(opts[k], &p) != nil || p.ProfileType != "FixedProfile" {
+			continue // switch/PAC/system profiles carry no proxy server
+		}
+		source := "profile " + firstNonEmpty(p.Name, strings.TrimPrefix(k, "+"))
+		for _, slot := range []struct {
+			name string
+			srv  *omegaServer
+		}{{"fallbackProxy", p.FallbackProxy}, {"proxyForHttp", p.ProxyForHTTP}, {"proxyForHttps", p.ProxyForHTTPS}} {
+			if slot.srv == nil || slot.srv.Host == "" {
+				continue
+			}
+			acc := p.Auth[slot.name]
+			e := newImportEntry(source, slot.srv.Scheme, slot.srv.Host, slot.srv.Port, acc.Username, acc.Password)
+			if e.Up != nil && e.Up.Notes == "" {
+				e.Up.Notes = p.Name
+			}
+			res = append(res, e)
+		}
+	}
+	return res, nil
+}
+
+// clashConfig is the subset of a Clash config we read
+type clashConfig struct {
+	Proxies []struct {
+		Name     string `yaml:"name"`
+		Type     string `yaml:"type"`
+		Server   string `yaml:"server"`
+		Port     any    `yaml:"port"`
+		Username string `yaml:"username"`
+		Password string `yaml:"password"`
+	} `yaml:"proxies"`
+}
+
+func parseImportClash(data []byte) ([]importEntry, error) {
+	var c clashConfig
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return nil, invalidf("clash: %v", err)
+	}
+	var res []importEntry
+	for i, p := range c.Proxies {
+		source := fmt.Sprintf("proxies[%d] %s", i, p.Name)
+		port, err := anyPort(p.Port)
+		if err != nil {
+			res = append(res, importEntry{Source: source, Err: err.Error()})
+			continue
+		}
+		e := newImportEntry(source, p.Type, p.Server, port, p.Username, p.Password)
+		if e.Up != nil {
+			e.Up.Notes = p.Name
+		}
+		res = append(res, e)
+	}
+	return res, nil
+}
+
+// singBoxConfig is the subset of a sing-box config we read
+type singBoxConfig struct {
+	Outbounds []struct {
+		Type       string `json:"type"`
+		Tag        string `json:"tag"`
+		Server     string `json:"server"`
+		ServerPort int    `json:"server_port"`
+		Username   string `json:"username"`
+		Password   string `json:"password"`
+	} `json:"outbounds"`
+}
+
+func parseImportSingBox(data []byte) ([]importEntry, error) {
+	var c singBoxConfig
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, invalidf("sing-box: %v", err)
+	}
+	var res []importEntry
+	for i, o := range c.Outbounds {
+		if o.Server == "" {
+			continue // direct, block, dns, selector, urltest
+		}
+		source := fmt.Sprintf("outbounds[%d] %s", i, o.Tag)
+		scheme := o.Type
+		if scheme == "socks" {
+			scheme = "socks5"
+		}
+		e := newImportEntry(source, scheme, o.Server, o.ServerPort, o.Username, o.Password)
+		if e.Up != nil {
+			e.Up.Notes = o.Tag
+		}
+		res = append(res, e)
+	}
+	return res, nil
+}
+
+// importEntries merges entries into the pool. New upstreams are added
+// stopped; existing ones get their connection fields updated (and are
+// restarted if running). Labels are only overwritten when the file has them.
+// With dryRun nothing is changed and the report shows what would happen.
+func (m *Manager) importEntries(entries []importEntry, dryRun bool) (*ImportReport, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rep := &ImportReport{DryRun: dryRun, Results: make([]ImportResult, 0, len(entries))}
+	seen := make(map[string]string) // id -> source of first occurrence
+	snapshotted := false
+	for _, e := range entries {
+		r := ImportResult{Source: e.Source}
+		switch {
+		case e.Up == nil && e.Skip != "":
+			r.Action, r.Reason = "skip", e.Skip
+			rep.Skipped++
+		case e.Up == nil:
+			r.Action, r.Reason = "error", e.Err
+			rep.Failed++
+		case seen[e.Up.ID] != "":
+			r.ID, r.Action, r.Reason = e.Up.ID, "skip", "duplicate of "+seen[e.Up.ID]
+			rep.Skipped++
+		default:
+			up := e.Up
+			seen[up.ID] = e.Source
+			r.ID = up.ID
+			it, exists := m.items[up.ID]
+			if !exists {
+				r.Action, r.Item = "add", up
+				rep.Added++
+				if !dryRun {
+					r.Item = m.addToPoolLocked(up)
+				}
+				break
+			}
+			next := *it.cfg
+			next.Host, next.Port, next.Scheme, next.User, next.Pass = up.Host, up.Port, up.Scheme, up.User, up.Pass
+			if len(up.Tags) > 0 {
+				next.Tags = up.Tags
+			}
+			if up.Notes != "" {
+				next.Notes = up.Notes
+			}
+			if up.Location != "" {
+				next.Location = up.Location
+			}
+			r.Changes = upstreamDiff(it.cfg, &next)
+			if len(r.Changes) == 0 {
+				r.Action, r.Reason = "skip", "unchanged"
+				rep.Skipped++
+				break
+			}
+			r.Action, r.Item = "update", &next
+			rep.Updated++
+			if dryRun {
+				break
+			}
+			if !snapshotted {
+				_, _ = m.snapshotLocked("import", true)
+				snapshotted = true
+			}
+			copyUpstreamConfig(it.cfg, &next)
+			r.Item = it.cfg
+			if it.isRunning && changesUpstream(r.Changes) {
+				if err := m.restartLocked(it); err != nil {
+					log.Printf("[Import] restart %s: %v", it.cfg.ID, err)
+				}
+			}
+		}
+		rep.Results = append(rep.Results, r)
+	}
+	if dryRun || rep.Added+rep.Updated == 0 {
+		return rep, nil
+	}
+	log.Printf("[Import] %d added, %d updated, %d skipped, %d failed", rep.Added, rep.Updated, rep.Skipped, rep.Failed)
+	return rep, m.saveState()
+}
+
+// importData detects the format (unless given), parses and imports data
+func (m *Manager) importData(format, name string, data []byte, dryRun bool) (*ImportReport, error) {
+	if format == "" || format == importAuto {
+		format = detectImportFormat(name, data)
+	}
+	entries, err := parseImport(format, data)
+	if err != nil {
+		return nil, err
+	}
+	rep, err := m.importEntries(entries, dryRun)
+	if rep != nil {
+		rep.Format = format
+	}
+	return rep, err
+}
+
+// readImportRequest reads the import options and file body of a request
+// Query: ?format=auto|lines|csv|json|proxifier|switchyomega|clash|singbox&name=file.ext&dry_run=1
+func readImportRequest(r *http.Request) (format, name string, dryRun bool, data []byte, err error) {
+	q := r.URL.Query()
+	format, name = strings.ToLower(q.Get("format")), q.Get("name")
+	dryRun = q.Get("dry_run") == "1" || q.Get("dry_run") == "true"
+	data, err = io.ReadAll(io.LimitReader(r.Body, maxImportSize))
+	return
+}
+
+// handleImport imports a proxy file posted as the raw request body
+func (m *Manager) handleImport(w http.ResponseWriter, r *http.Request) {
+	if !m.handleAuth(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	format, name, dryRun, data, err := readImportRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	rep, err := m.importData(format, name, data, dryRun)
+	if rep == nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	writeJSON(w, http.StatusOK, rep)
+}
+
+// handleV1Import is the /api/v1 variant of handleImport
+func (m *Manager) handleV1Import(w http.ResponseWriter, r *http.Request) {
+	format, name, dryRun, data, err := readImportRequest(r)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+	rep, err := m.importData(format, name, data, dryRun)
+	if err != nil {
+		writeManagerError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, rep)
+}
+
+// runImportCommand implements `proxy-fwd import <file> [--dry-run] [--format=X]`.
+// It edits the state file directly; a running instance picks the change up
+// through the state watcher.
+func runImportCommand(args []string) int {
+	var file, format string
+	dryRun := false
+	for _, a := range args {
+		switch {
+		case a == "--dry-run" || a == "-n":
+			dryRun = true
+		case strings.HasPrefix(a, "--format="):
+			format = strings.TrimPrefix(a, "--format=")
+		case strings.HasPrefix(a, "-"):
+			fmt.Fprintf(os.Stderr, "unknown flag %s\n", a)
+			return 2
+		default:
+			file = a
+		}
+	}
+	if file == "" {
+		fmt.Fprintln(os.Stderr, "usage: proxy-fwd import <file> [--dry-run] [--format="+strings.Join(importFormats, "|")+"]")
+		return 2
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	m := NewManager("")
+	// the same store the server uses, so remote list credentials resolve
+	secretsPath, secretsKey := secretsPaths()
+	if m.secrets, err = openSecrets(secretsPath, secretsKey); err != nil {
+		fmt.Fprintf(os.Stderr, "secrets: %v\n", err)
+		return 1
+	}
+	if err := m.loadState(); err != nil && !os.IsNotExist(err) {
+		fmt.Fprintf(os.Stderr, "load state: %v\n", err)
+		return 1
+	}
+	rep, err := m.importData(format, file, data, dryRun)
+	if rep == nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	for _, r := range rep.Results {
+		detail := r.Reason
+		if len(r.Changes) > 0 {
+			detail = strings.Join(r.Changes, ", ")
+		}
+		fmt.Printf("%-7s %-32s %-24s %s\n", r.Action, r.ID, r.Source, detail)
+	}
+	verb := "imported"
+	if rep.DryRun {
+		verb = "dry run"
+	}
+	fmt.Printf("%s (%s): %d added, %d updated, %d skipped, %d failed\n", verb, rep.Format, rep.Added, rep.Updated, rep.Skipped, rep.Failed)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "save state: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,555 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Order job states
+const (
+	jobQueued   = "queued"
+	jobOrdering = "ordering" // placing the order
+	jobWaiting  = "waiting"  // polling the vendor until the order is ready
+	jobSyncing  = "syncing"  // adding the delivered proxies to the pool
+	jobDone     = "done"
+	jobFailed   = "failed"
+	jobCanceled = "canceled"
+)
+
+const (
+	jobsFileName     = "order_jobs.yaml"
+	jobPollInterval  = 5 * time.Second
+	jobOrderInterval = 2 * time.Second  // between vendor orders of one job
+	jobTimeout       = 30 * time.Minute // from creation until the vendor must deliver
+	jobsKeep         = 100              // finished jobs kept in the file
+	jobMaxQuantity   = 1000
+)
+
+// OrderJob is a vendor purchase processed in the background: its quantity is
+// placed as one or more vendor orders, each polled until ready, then the
+// delivered proxies are synced into the pool (and optionally started). Jobs
+// are persisted in order_jobs.yaml and resumed after a restart.
+type OrderJob struct {
+	ID       string       `yaml:"id" json:"id"`
+	Provider string       `yaml:"provider" json:"provider"`
+	Request  OrderRequest `yaml:"request" json:"request"`
+	Start    bool         `yaml:"start,omitempty" json:"start"`
+	Token    string       `yaml:"-" json:"-"`                                     // per-request token, memory only
+	OwnToken bool         `yaml:"own_token,omitempty" json:"own_token,omitempty"` // submitted with Token; cannot resume without it
+	Status   string       `yaml:"status" json:"status"`
+	Orders   []*JobOrder  `yaml:"orders,omitempty" json:"orders"`
+	Error    string       `yaml:"error,omitempty" json:"error,omitempty"`
+	Result   *SyncResult  `yaml:"result,omitempty" json:"result,omitempty"`
+	ProxyIDs []string     `yaml:"proxy_ids,omitempty" json:"proxy_ids,omitempty"` // pool IDs of the delivered proxies
+	Created  time.Time    `yaml:"created" json:"created"`
+	Updated  time.Time    `yaml:"updated" json:"updated"`
+}
+
+// Vendor order states within a job
+const (
+	orderPending = "pending"
+	orderPlacing = "placing"
+	orderWaiting = "waiting"
+	orderReady   = "ready"  // vendor reports the order complete
+	orderDone    = "done"   // proxies fetched
+	orderFailed  = "failed" // see Error
+)
+
+// JobOrder is one vendor order of a job
+type JobOrder struct {
+	Quantity     int    `yaml:"quantity" json:"quantity"`
+	OrderID      string `yaml:"order_id,omitempty" json:"order_id,omitempty"`
+	Status       string `yaml:"status" json:"status"`
+	VendorStatus string `yaml:"vendor_status,omitempty" json:"vendor_status,omitempty"`
+	Polls        int    `yaml:"polls,omitempty" json:"polls"`
+	Delivered    int    `yaml:"delivered,omitempty" json:"delivered"`
+	Error        string `yaml:"error,omitempty" json:"error,omitempty"`
+}
+
+// splitQuantity plans one vendor order per proxy: provider order calls take
+// no quantity
+func splitQuantity(quantity int) []*JobOrder {
+	orders := make([]*JobOrder, 0, quantity)
+	for i := 0; i < quantity; i++ {
+		orders = append(orders, &JobOrder{Quantity: 1, Status: orderPending})
+	}
+	return orders
+}
+
+// finished reports whether the job reached a final state
+func (j *OrderJob) finished() bool {
+	return j.Status == jobDone || j.Status == jobFailed || j.Status == jobCanceled
+}
+
+// jobStore holds order jobs; it has its own lock so that slow vendor calls
+// never hold the Manager lock
+type jobStore struct {
+	mu     sync.Mutex
+	items  map[string]*OrderJob
+	cancel map[string]context.CancelFunc
+	seq    int
+}
+
+func jobsFile() string {
+	return filepath.Join(filepath.Dir(stateFile), jobsFileName)
+}
+
+// saveLocked writes the jobs file, dropping the oldest finished jobs beyond
+// jobsKeep (must be called with jobStore lock held)
+func (s *jobStore) saveLocked() {
+	all := s.listLocked()
+	kept, finished := make([]*OrderJob, 0, len(all)), 0
+	for _, j := range all {
+		if j.finished() {
+			if finished++; finished > jobsKeep {
+				delete(s.items, j.ID)
+				continue
+			}
+		}
+		kept = append(kept, j)
+	}
+	b, err := yaml.Marshal(kept)
+	if err == nil {
+		err = os.WriteFile(jobsFile(), b, 0600)
+	}
+	if err != nil {
+		log.Printf("[Jobs] save failed: %v", err)
+	}
+}
+
+// listLocked returns the jobs newest first
+func (s *jobStore) listLocked() []*OrderJob {
+	res := make([]*OrderJob, 0, len(s.items))
+	for _, j := range s.items {
+		res = append(res, j)
+	}
+	sort.Slice(res, func(a, b int) bool { return res[a].Created.After(res[b].Created) })
+	return res
+}
+
+// loadJobs reads order_jobs.yaml and resumes unfinished jobs; jobs submitted
+// with their own token are failed instead, since the token was not kept
+func (m *Manager) loadJobs() {
+	b, err := os.ReadFile(jobsFile())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("[Jobs] load failed: %v", err)
+		}
+		return
+	}
+	var list []*OrderJob
+	if err := yaml.Unmarshal(b, &list); err != nil {
+		log.Printf("[Jobs] load failed: %v", err)
+		return
+	}
+	m.jobs.mu.Lock()
+	defer m.jobs.mu.Unlock()
+	failed := false
+	for _, j := range list {
+		if j == nil || j.ID == "" {
+			continue
+		}
+		m.jobs.items[j.ID] = j
+		if n := jobSeq(j.ID); n > m.jobs.seq {
+			m.jobs.seq = n
+		}
+		switch {
+		case j.finished():
+		case j.OwnToken:
+			// the request's token was not written to the file, and the
+			// configured one may belong to another account
+			j.Status, j.Error, j.Updated = jobFailed, "token not persisted; resubmit", time.Now()
+			failed = true
+			log.Printf("[Jobs] %s failed: %s", j.ID, j.Error)
+		default:
+			log.Printf("[Jobs] resuming %s (%s, %s)", j.ID, j.Provider, j.Status)
+			m.runJobLocked(j)
+		}
+	}
+	if failed {
+		m.jobs.saveLocked()
+	}
+}
+
+// jobSeq returns the sequence number at the end of a job ID, 0 if there is none.
+// IDs of pruned jobs may be missing, so the next one continues after the highest.
+func jobSeq(id string) int {
+	n, _ := strconv.Atoi(id[strings.LastIndex(id, "-")+1:])
+	return n
+}
+
+// createJob queues an order and starts processing it; token "" uses the
+// provider's server-side token, which is then not written to the job file
+func (m *Manager) createJob(provider, token string, req OrderRequest, start bool) (*OrderJob, error) {
+	if _, ok := getProvider(provider); !ok {
+		return nil, invalidf("unknown provider %q", provider)
+	}
+	if token == "" && m.configuredToken(provider) == "" {
+		return nil, invalidf("no %s token: send one or store it with PUT /api/v1/secrets/%s", provider, provider)
+	}
+	if req.Type == "" || req.Region == "" {
+		return nil, invalidf("type and region required")
+	}
+	if req.Quantity < 1 {
+		req.Quantity = 1
+	}
+	if req.Quantity > jobMaxQuantity {
+		return nil, invalidf("quantity must be at most %d", jobMaxQuantity)
+	}
+	now := time.Now()
+	m.jobs.mu.Lock()
+	defer m.jobs.mu.Unlock()
+	m.jobs.seq++
+	j := &OrderJob{
+		ID:       now.Format("20060102-150405") + "-" + strconv.Itoa(m.jobs.seq),
+		Provider: provider,
+		Request:  req,
+		Start:    start,
+		Token:    token,
+		OwnToken: token != "",
+		Status:   jobQueued,
+		Created:  now,
+		Updated:  now,
+	}
+	m.jobs.items[j.ID] = j
+	m.jobs.saveLocked()
+	log.Printf("[Jobs] %s queued: %s %s/%s x%d", j.ID, provider, req.Type, req.Region, req.Quantity)
+	m.runJobLocked(j)
+	return m.jobView(j), nil
+}
+
+// runJobLocked starts the job goroutine (must be called with jobStore lock held)
+func (m *Manager) runJobLocked(j *OrderJob) {
+	ctx, cancel := context.WithCancel(context.Background())
+	m.jobs.cancel[j.ID] = cancel
+	go m.runJob(ctx, j)
+}
+
+// updateJob applies fn to a job that is still active and persists it;
+// it returns false once the job has been canceled
+func (m *Manager) updateJob(j *OrderJob, fn func(j *OrderJob)) bool {
+	m.jobs.mu.Lock()
+	defer m.jobs.mu.Unlock()
+	if j.finished() {
+		return false
+	}
+	fn(j)
+	j.Updated = time.Now()
+	if j.finished() {
+		j.Token = ""
+		delete(m.jobs.cancel, j.ID)
+	}
+	m.jobs.saveLocked()
+	return true
+}
+
+// failJob marks a job failed
+func (m *Manager) failJob(j *OrderJob, err error) {
+	m.updateJob(j, func(j *OrderJob) {
+		j.Status = jobFailed
+		j.Error = err.Error()
+	})
+	log.Printf("[Jobs] %s failed: %v", j.ID, err)
+}
+
+// runJob places the job's vendor orders, polls them and syncs what they
+// deliver; orders fail independently and the job fails only when none
+// delivers anything
+func (m *Manager) runJob(ctx context.Context, j *OrderJob) {
+	m.jobs.mu.Lock()
+	provider, token, req := j.Provider, j.Token, j.Request
+	m.jobs.mu.Unlock()
+	if token == "" {
+		token = m.configuredToken(provider)
+	}
+
+	p, ok := getProvider(provider)
+	if !ok {
+		m.failJob(j, fmt.Errorf("unknown provider %q", provider))
+		return
+	}
+
+	// plan the vendor orders; an order interrupted while being placed may
+	// or may not exist at the vendor, so it is not placed again
+	var orders []*JobOrder
+	if !m.updateJob(j, func(j *OrderJob) {
+		if len(j.Orders) == 0 {
+			j.Orders = splitQuantity(req.Quantity)
+		}
+		for _, o := range j.Orders {
+			if o.Status == orderPlacing {
+				o.Status, o.Error = orderFailed, "interrupted while ordering; check the vendor account"
+			}
+		}
+		j.Status = jobOrdering
+		orders = j.Orders
+	}) {
+		return
+	}
+
+	placed := 0
+	for i, o := range orders {
+		if o.Status != orderPending {
+			continue
+		}
+		if placed > 0 && !sleepCtx(ctx, jobOrderInterval) {
+			return
+		}
+		placed++
+		if !m.updateJob(j, func(*OrderJob) { o.Status = orderPlacing }) {
+			return
+		}
+		oreq := req
+		oreq.Quantity = o.Quantity
+		id, err := p.Order(ctx, token, oreq)
+		if ctx.Err() != nil {
+			return
+		}
+		if !m.updateJob(j, func(*OrderJob) {
+			if err != nil {
+				o.Status, o.Error = orderFailed, err.Error()
+			} else {
+				o.Status, o.OrderID = orderWaiting, id
+			}
+		}) {
+			return
+		}
+		if err != nil {
+			log.Printf("[Jobs] %s order %d/%d failed: %v", j.ID, i+1, len(orders), err)
+		} else {
+			log.Printf("[Jobs] %s placed %s order %s (%d/%d, x%d)", j.ID, provider, id, i+1, len(orders), o.Quantity)
+		}
+	}
+
+	if !m.updateJob(j, func(j *OrderJob) { j.Status = jobWaiting }) {
+		return
+	}
+	t := time.NewTicker(jobPollInterval)
+	defer t.Stop()
+	for waiting := countOrders(orders, orderWaiting); waiting > 0; {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		for _, o := range orders {
+			if o.Status != orderWaiting {
+				continue
+			}
+			st, err := p.OrderStatus(ctx, token, o.OrderID)
+			if ctx.Err() != nil {
+				return
+			}
+			if !m.updateJob(j, func(j *OrderJob) {
+				o.Polls++
+				o.Error = ""
+				switch {
+				case err != nil:
+					// transient: keep polling until the timeout
+					o.Error = err.Error()
+				case st.Failed:
+					o.Status, o.VendorStatus, o.Error = orderFailed, st.Status, "order "+st.Status
+				case st.Done:
+					o.Status, o.VendorStatus = orderReady, st.Status
+				default:
+					o.VendorStatus = st.Status
+				}
+				if o.Status == orderWaiting && time.Since(j.Created) > jobTimeout {
+					o.Status, o.Error = orderFailed, fmt.Sprintf("not ready after %s", jobTimeout)
+				}
+			}) {
+				return
+			}
+		}
+		waiting = countOrders(orders, orderWaiting)
+	}
+
+	if !m.updateJob(j, func(j *OrderJob) { j.Status = jobSyncing }) {
+		return
+	}
+	var ps []ProviderProxy
+	for _, o := range orders {
+		if o.Status != orderReady {
+			continue
+		}
+		got, err := p.Proxies(ctx, token, o.OrderID)
+		if ctx.Err() != nil {
+			return
+		}
+		if !m.updateJob(j, func(*OrderJob) {
+			switch {
+			case err != nil:
+				o.Status, o.Error = orderFailed, err.Error()
+			case len(got) == 0:
+				o.Status, o.Error = orderFailed, "no proxies returned"
+			default:
+				o.Status, o.Delivered = orderDone, len(got)
+				if len(got) < o.Quantity {
+					o.Error = fmt.Sprintf("delivered %d of %d", len(got), o.Quantity)
+				}
+			}
+		}) {
+			return
+		}
+		ps = append(ps, got...)
+	}
+	if len(ps) == 0 {
+		m.failJob(j, fmt.Errorf("no order delivered proxies (%d failed)", countOrders(orders, orderFailed)))
+		return
+	}
+
+	res := m.syncIntoPool(provider, ps)
+	for i, o := range orders {
+		if o.Error != "" {
+			res.Errors = append(res.Errors, fmt.Sprintf("order %d/%d %s: %s", i+1, len(orders), o.OrderID, o.Error))
+		}
+	}
+	// entries matched by vendor ID keep their own ID, so take the IDs from the sync
+	ids := res.IDs
+	if j.Start && len(ids) > 0 {
+		results, err := m.bulkStart(&bulkTarget{IDs: ids})
+		if err != nil {
+			res.Errors = append(res.Errors, "start: "+err.Error())
+		}
+		for _, r := range results {
+			if !r.OK {
+				res.Errors = append(res.Errors, fmt.Sprintf("start %s: %s", r.ID, r.Error))
+			}
+		}
+	}
+	m.updateJob(j, func(j *OrderJob) {
+		j.Status = jobDone
+		j.Result = &res
+		j.ProxyIDs = ids
+	})
+	log.Printf("[Jobs] %s done: %d of %d proxies (%d added, %d orders failed)",
+		j.ID, len(ids), req.Quantity, res.Added, countOrders(orders, orderFailed))
+}
+
+// countOrders counts the orders in a state (must be called with jobStore
+// lock held, or from the job goroutine, which is the only writer)
+func countOrders(orders []*JobOrder, status string) int {
+	n := 0
+	for _, o := range orders {
+		if o.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// sleepCtx waits for d; it returns false if ctx ended first
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+// cancelJob stops processing a job; an order already placed is not
+// canceled at the vendor
+func (m *Manager) cancelJob(id string) (*OrderJob, error) {
+	m.jobs.mu.Lock()
+	defer m.jobs.mu.Unlock()
+	j, ok := m.jobs.items[id]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	if j.finished() {
+		return nil, invalidf("job already %s", j.Status)
+	}
+	if cancel := m.jobs.cancel[id]; cancel != nil {
+		cancel()
+		delete(m.jobs.cancel, id)
+	}
+	j.Status = jobCanceled
+	j.Token = ""
+	j.Updated = time.Now()
+	m.jobs.saveLocked()
+	log.Printf("[Jobs] %s canceled", id)
+	return m.jobView(j), nil
+}
+
+// jobView copies a job for a response (must be called with jobStore lock held)
+func (m *Manager) jobView(j *OrderJob) *OrderJob {
+	cp := *j
+	cp.Orders = make([]*JobOrder, len(j.Orders))
+	for i, o := range j.Orders {
+		oc := *o
+		cp.Orders[i] = &oc
+	}
+	return &cp
+}
+
+// handleV1JobCreate queues an order job
+// Body: {"provider": "cloudmini", "type": "proxy-res", "region": "VN", "quantity": 1, "start": true}
+func (m *Manager) handleV1JobCreate(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		OrderRequest
+		Provider string `json:"provider"`
+		Start    bool   `json:"start"`
+	}
+	if !decodeJSON(w, r, &body) {
+		return
+	}
+	j, err := m.createJob(body.Provider, r.Header.Get("X-Provider-Token"), body.OrderRequest, body.Start)
+	if err != nil {
+		writeManagerError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, j)
+}
+
+// handleV1Jobs lists order jobs, newest first
+func (m *Manager) handleV1Jobs(w http.ResponseWriter, r *http.Request) {
+	m.jobs.mu.Lock()
+	items := make([]*OrderJob, 0, len(m.jobs.items))
+	for _, j := range m.jobs.listLocked() {
+		items = append(items, m.jobView(j))
+	}
+	m.jobs.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]any{"items": items})
+}
+
+// handleV1Job returns one order job
+func (m *Manager) handleV1Job(w http.ResponseWriter, r *http.Request) {
+	m.jobs.mu.Lock()
+	j, ok := m.jobs.items[r.PathValue("id")]
+	var view *OrderJob
+	if ok {
+		view = m.jobView(j)
+	}
+	m.jobs.mu.Unlock()
+	if !ok {
+		writeAPIError(w, http.StatusNotFound, codeNotFound, "job not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, view)
+}
+
+// handleV1JobCancel cancels an order job
+func (m *Manager) handleV1JobCancel(w http.ResponseWriter, r *http.Request) {
+	j, err := m.cancelJob(r.PathValue("id"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			writeAPIError(w, http.StatusNotFound, codeNotFound, "job not found")
+			return
+		}
+		writeManagerError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, j)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJobTokenIsNotWritten(t *testing.T) {
+	m := newTestManager(t)
+	m.jobs.mu.Lock()
+	m.jobs.items["j1"] = &OrderJob{ID: "j1", Provider: "cloudmini", Token: "request-token", Status: jobWaiting, Created: time.Now()}
+	m.jobs.saveLocked()
+	m.jobs.mu.Unlock()
+
+	b, err := os.ReadFile(jobsFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "request-token") {
+		t.Errorf("order_jobs.yaml holds the request token:\n%s", b)
+	}
+}
+
+func TestLoadJobsContinuesSequence(t *testing.T) {
+	m := newTestManager(t)
+	m.jobs.mu.Lock()
+	// job 2 was pruned: counting the jobs gives 2, so the next job would reuse 3
+	m.jobs.items["20260101-120000-1"] = &OrderJob{ID: "20260101-120000-1", Provider: "cloudmini", Status: jobDone, Created: time.Now()}
+	m.jobs.items["20260101-120500-3"] = &OrderJob{ID: "20260101-120500-3", Provider: "cloudmini", Status: jobDone, Created: time.Now()}
+	m.jobs.saveLocked()
+	m.jobs.mu.Unlock()
+
+	m2 := NewManager("")
+	m2.loadJobs()
+	if m2.jobs.seq != 3 {
+		t.Errorf("seq = %d, want 3 (the highest existing job)", m2.jobs.seq)
+	}
+}
+
+func TestLoadJobsFailsJobsWithLostToken(t *testing.T) {
+	m := newTestManager(t)
+	m.jobs.mu.Lock()
+	m.jobs.items["20260101-120000-1"] = &OrderJob{ID: "20260101-120000-1", Provider: "cloudmini", Token: "request-token", OwnToken: true, Status: jobWaiting, Created: time.Now()}
+	m.jobs.saveLocked()
+	m.jobs.mu.Unlock()
+
+	m2 := NewManager("")
+	m2.loadJobs()
+	m2.jobs.mu.Lock()
+	defer m2.jobs.mu.Unlock()
+	j := m2.jobs.items["20260101-120000-1"]
+	if j.Status != jobFailed || !strings.Contains(j.Error, "resubmit") {
+		t.Errorf("job after restart: %s %q, want failed asking to resubmit", j.Status, j.Error)
+	}
+	if m2.jobs.cancel[j.ID] != nil {
+		t.Error("job with a lost token was resumed")
+	}
+}
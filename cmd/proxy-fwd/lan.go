@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// lanConfig is the opt-in LAN sharing mode: listeners bind on a chosen
+// interface, accept only allowlisted client networks and require credentials.
+type lanConfig struct {
+	Bind      netip.Addr     // listen address (may be 0.0.0.0)
+	Advertise string         // host written into exports and PAC scripts
+	Allow     []netip.Prefix // client networks; loopback is always allowed
+}
+
+// parseLANConfig reads LAN_BIND (IP or interface name), LAN_ALLOW (comma
+// separated CIDRs, required) and LAN_ADVERTISE; an empty bind keeps the
+// loopback-only default and returns nil
+func parseLANConfig(bind, allow, advertise string) (*lanConfig, error) {
+	bind = strings.TrimSpace(bind)
+	if bind == "" {
+		return nil, nil
+	}
+	c := &lanConfig{}
+	addr, err := netip.ParseAddr(bind)
+	if err != nil {
+		if addr, err = interfaceAddr(bind); err != nil {
+			return nil, err
+		}
+	}
+	c.Bind = addr.Unmap()
+	for _, s := range strings.Split(allow, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		p, err := parsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("LAN_ALLOW: %v", err)
+		}
+		c.Allow = append(c.Allow, p)
+	}
+	if len(c.Allow) == 0 {
+		return nil, fmt.Errorf("LAN_ALLOW is required with LAN_BIND (e.g. 192.168.56.0/24)")
+	}
+	c.Advertise = strings.TrimSpace(advertise)
+	if c.Advertise == "" {
+		c.Advertise = c.Bind.String()
+		if c.Bind.IsUnspecified() {
+			c.Advertise = "127.0.0.1"
+			if a, err := interfaceAddr(""); err == nil {
+				c.Advertise = a.String()
+			}
+		}
+	}
+	return c, nil
+}
+
+// interfaceAddr returns the first IPv4 address of the named interface, or of
+// the first non-loopback interface that is up when name is empty
+func interfaceAddr(name string) (netip.Addr, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	for _, ifc := range ifaces {
+		if name != "" && ifc.Name != name {
+			continue
+		}
+		if name == "" && (ifc.Flags&net.FlagUp == 0 || ifc.Flags&net.FlagLoopback != 0) {
+			continue
+		}
+		addrs, _ := ifc.Addrs()
+		for _, a := range addrs {
+			if n, ok := a.(*net.IPNet); ok {
+				if ip, ok := netip.AddrFromSlice(n.IP); ok && ip.Unmap().Is4() {
+					return ip.Unmap(), nil
+				}
+			}
+		}
+	}
+	if name == "" {
+		return netip.Addr{}, fmt.Errorf("no LAN interface found")
+	}
+	return netip.Addr{}, fmt.Errorf("LAN_BIND: no IPv4 address on interface %q", name)
+}
+
+// allowed reports whether a client address may use the listeners
+func (c *lanConfig) allowed(addr net.Addr) bool {
+	ap, err := netip.ParseAddrPort(addr.String())
+	if err != nil {
+		return false
+	}
+	ip := ap.Addr().Unmap()
+	if ip.IsLoopback() {
+		return true
+	}
+	for _, p := range c.Allow {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// listenHost is the address listeners bind to
+func (m *Manager) listenHost() string {
+	if m.lan != nil {
+		return m.lan.Bind.String()
+	}
+	return "127.0.0.1"
+}
+
+// clientHost is the host clients use to reach the listeners (exports, PAC)
+func (m *Manager) clientHost() string {
+	if m.lan != nil {
+		return m.lan.Advertise
+	}
+	return "127.0.0.1"
+}
+
+// dialHost is the host this process uses to reach its own listeners
+func (m *Manager) dialHost() string {
+	if m.lan != nil && !m.lan.Bind.IsUnspecified() {
+		return m.lan.Bind.String()
+	}
+	return "127.0.0.1"
+}
+
+// listenAddr is the host:port a listener binds to
+func (m *Manager) listenAddr(port int) string {
+	return net.JoinHostPort(m.listenHost(), strconv.Itoa(port))
+}
+
+// lanListener drops connections from clients outside the allowlist
+type lanListener struct {
+	net.Listener
+	lan *lanConfig
+	id  string
+}
+
+func (l *lanListener) Accept() (net.Conn, error) {
+	for {
+		c, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if l.lan.allowed(c.RemoteAddr()) {
+			return c, nil
+		}
+		log.Printf("[proxy %s] rejected client %s (not in LAN_ALLOW)", l.id, c.RemoteAddr())
+		c.Close()
+	}
+}
+
+// handleV1LAN reports the listener binding and client allowlist
+func (m *Manager) handleV1LAN(w http.ResponseWriter, r *http.Request) {
+	res := map[string]any{
+		"enabled":   m.lan != nil,
+		"bind":      m.listenHost(),
+		"advertise": m.clientHost(),
+		"allow":     []string{},
+	}
+	if m.lan != nil {
+		allow := make([]string, len(m.lan.Allow))
+		for i, p := range m.lan.Allow {
+			allow[i] = p.String()
+		}
+		res["allow"] = allow
+	}
+	writeJSON(w, http.StatusOK, res)
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const maxListLimit = 1000
+
+// listSortKeys maps a sort name to the key used for ordering; numeric keys
+// are zero padded so that all keys compare as strings
+var listSortKeys = map[string]func(up *Upstream) string{
+	"local_port": func(up *Upstream) string { return padInt(up.LocalPort) },
+	"id":         func(up *Upstream) string { return up.ID },
+	"host":       func(up *Upstream) string { return strings.ToLower(up.Host) },
+	"port":       func(up *Upstream) string { return padInt(up.Port) },
+	"status":     func(up *Upstream) string { return up.Status },
+	"type":       func(up *Upstream) string { return up.ProxyType },
+	"location":   func(up *Upstream) string { return strings.ToLower(up.Location) },
+}
+
+// padInt formats a port as a 6-digit key without fmt's overhead
+func padInt(n int) string {
+	s := strconv.Itoa(n)
+	if len(s) >= 6 {
+		return s
+	}
+	return "000000"[len(s):] + s
+}
+
+// listQuery holds filters, ordering and paging for /api/list
+type listQuery struct {
+	Status   []string // any of
+	Type     []string // any of
+	Location string   // case-insensitive exact match
+	Tags     []string // all of
+	Search   string   // case-insensitive substring of id, host, user, location, notes, tags
+	Sort     string
+	Desc     bool
+	Cursor   string
+	Limit    int // 0 = no paging
+}
+
+// listCursor is the position after the last returned item
+type listCursor struct {
+	Key string `json:"k"`
+	ID  string `json:"id"`
+}
+
+// splitList splits a comma separated query value, dropping empty entries
+func splitList(s string) []string {
+	var res []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			res = append(res, strings.ToLower(v))
+		}
+	}
+	return res
+}
+
+// parseListQuery reads list parameters from the query string
+// (status, type, location, tag, q, sort, order, cursor, limit)
+func parseListQuery(v url.Values) (listQuery, error) {
+	q := listQuery{
+		Status:   splitList(v.Get("status")),
+		Type:     splitList(v.Get("type")),
+		Location: strings.TrimSpace(v.Get("location")),
+		Tags:     parseTagSelector(v.Get("tag")),
+		Search:   strings.ToLower(strings.TrimSpace(v.Get("q"))),
+		Sort:     v.Get("sort"),
+		Cursor:   v.Get("cursor"),
+	}
+	if q.Sort == "" {
+		q.Sort = "local_port"
+	}
+	if _, ok := listSortKeys[q.Sort]; !ok {
+		return q, invalidf("invalid sort %q", q.Sort)
+	}
+	switch v.Get("order") {
+	case "", "asc":
+	case "desc":
+		q.Desc = true
+	default:
+		return q, invalidf("invalid order %q", v.Get("order"))
+	}
+	if s := v.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return q, invalidf("invalid limit %q", s)
+		}
+		q.Limit = min(n, maxListLimit)
+	}
+	return q, nil
+}
+
+// match reports whether up passes the query filters
+func (q *listQuery) match(up *Upstream) bool {
+	if len(q.Status) > 0 && !containsFold(q.Status, up.Status) {
+		return false
+	}
+	if len(q.Type) > 0 && !containsFold(q.Type, up.ProxyType) {
+		return false
+	}
+	if q.Location != "" && !strings.EqualFold(q.Location, up.Location) {
+		return false
+	}
+	if !hasTags(up, q.Tags) {
+		return false
+	}
+	if q.Search != "" {
+		hay := strings.ToLower(strings.Join([]string{
+			up.ID, up.Host, up.User, up.Location, up.Notes, strings.Join(up.Tags, " "),
+		}, "\n"))
+		if !strings.Contains(hay, q.Search) {
+			return false
+		}
+	}
+	return true
+}
+
+// containsFold reports whether list contains s, ignoring case
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// sortUpstreams orders items by key then ID. Keys are computed once per item
+// rather than in every comparison.
+func sortUpstreams(items []*Upstream, key func(*Upstream) string, desc bool) {
+	keyed := make([]keyedUpstream, len(items))
+	for i, up := range items {
+		keyed[i] = keyedUpstream{key: key(up), up: up}
+	}
+	sort.Slice(keyed, func(i, j int) bool {
+		ki, kj := keyed[i].key, keyed[j].key
+		if ki != kj {
+			return (ki < kj) != desc
+		}
+		return (keyed[i].up.ID < keyed[j].up.ID) != desc
+	})
+	for i := range keyed {
+		items[i] = keyed[i].up
+	}
+}
+
+// keyedUpstream is an item with its precomputed sort key
+type keyedUpstream struct {
+	key string
+	up  *Upstream
+}
+
+// query returns one page of filtered, sorted items, the total number of
+// matches and the cursor for the next page ("" on the last page)
+func (m *Manager) query(q listQuery) ([]*Upstream, int, string, error) {
+	key := listSortKeys[q.Sort]
+	m.mu.RLock()
+	items := make([]*Upstream, 0, len(m.items))
+	for _, it := range m.items {
+		if q.match(it.cfg) {
+			items = append(items, it.cfg)
+		}
+	}
+	m.mu.RUnlock()
+	sortUpstreams(items, key, q.Desc)
+	total := len(items)
+
+	if q.Cursor != "" {
+		raw, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+		var c listCursor
+		if err == nil {
+			err = json.Unmarshal(raw, &c)
+		}
+		if err != nil {
+			return nil, 0, "", fmt.Errorf("invalid cursor")
+		}
+		// first item strictly after the cursor position
+		start := sort.Search(len(items), func(i int) bool {
+			k, id := key(items[i]), items[i].ID
+			if k != c.Key {
+				return (k > c.Key) != q.Desc
+			}
+			return id != c.ID && (id > c.ID) != q.Desc
+		})
+		items = items[start:]
+	}
+
+	next := ""
+	if q.Limit > 0 && len(items) > q.Limit {
+		items = items[:q.Limit]
+		last := items[len(items)-1]
+		b, _ := json.Marshal(listCursor{Key: key(last), ID: last.ID})
+		next = base64.RawURLEncoding.EncodeToString(b)
+	}
+	return items, total, next, nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSortUpstreams(t *testing.T) {
+	items := []*Upstream{
+		{ID: "c", LocalPort: 10002},
+		{ID: "b", LocalPort: 9999},
+		{ID: "a", LocalPort: 10002},
+	}
+	sortUpstreams(items, listSortKeys["local_port"], false)
+	var got string
+	for _, up := range items {
+		got += up.ID
+	}
+	if got != "bac" {
+		t.Errorf("order = %q, want bac (by port, then ID)", got)
+	}
+	if k := listSortKeys["port"](&Upstream{Port: 80}); k != "000080" {
+		t.Errorf("port key = %q, want 000080", k)
+	}
+}
+
+// TestSortUpstreamsComputesKeysOnce checks that keys are not rebuilt in
+// every comparison
+func TestSortUpstreamsComputesKeysOnce(t *testing.T) {
+	items := make([]*Upstream, 500)
+	for i := range items {
+		items[i] = &Upstream{ID: fmt.Sprint(i), LocalPort: 10000 + (i*7919)%500}
+	}
+	calls := 0
+	key := func(up *Upstream) string {
+		calls++
+		return listSortKeys["local_port"](up)
+	}
+	sortUpstreams(items, key, true)
+	if calls != len(items) {
+		t.Errorf("key computed %d times for %d items", calls, len(items))
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// localProxyURL is the URL of a running listener on host, including its
+// credentials when listener authentication is enabled
+func localProxyURL(host string, up *Upstream) *url.URL {
+	u := &url.URL{Scheme: "http", Host: net.JoinHostPort(host, strconv.Itoa(up.LocalPort))}
+	if up.LocalUser != "" {
+		u.User = url.UserPassword(up.LocalUser, up.LocalPass)
+	}
+	return u
+}
+
+// randomToken returns n random bytes hex encoded
+func randomToken(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// generateLocalAuthLocked gives up listener credentials if it has none
+func generateLocalAuthLocked(up *Upstream) {
+	if up.LocalUser == "" {
+		up.LocalUser = "pf-" + randomToken(4)
+		up.LocalPass = randomToken(12)
+	}
+}
+
+// proxyAuthorized checks the Basic Proxy-Authorization header of a request
+func proxyAuthorized(r *http.Request, user, pass string) bool {
+	h := r.Header.Get("Proxy-Authorization")
+	enc, ok := strings.CutPrefix(h, "Basic ")
+	if !ok {
+		return false
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(enc))
+	if err != nil {
+		return false
+	}
+	u, p, _ := strings.Cut(string(raw), ":")
+	return subtle.ConstantTimeCompare([]byte(u), []byte(user))&subtle.ConstantTimeCompare([]byte(p), []byte(pass)) == 1
+}
+
+// setLocalAuth enables listener authentication with the given or generated
+// credentials, or disables it; a running listener is restarted
+func (m *Manager) setLocalAuth(id string, enable bool, user, pass string) (*Upstream, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	it, ok := m.items[id]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	switch {
+	case !enable:
+		if m.requireLocalAuth {
+			return nil, invalidf("listener authentication is required (LOCAL_AUTH or LAN mode)")
+		}
+		it.cfg.LocalUser, it.cfg.LocalPass = "", ""
+	case user != "" || pass != "":
+		if user == "" || pass == "" || strings.Contains(user, ":") {
+			return nil, invalidf("user and pass required; user must not contain ':'")
+		}
+		it.cfg.LocalUser, it.cfg.LocalPass = user, pass
+	default:
+		it.cfg.LocalUser = ""
+		generateLocalAuthLocked(it.cfg)
+	}
+	if it.isRunning {
+		if err := m.restartLocked(it); err != nil {
+			log.Printf("[proxy %s] restart after auth change: %v", id, err)
+		}
+	}
+	if enable {
+		log.Printf("[proxy %s] listener auth enabled for user %s", id, it.cfg.LocalUser)
+	} else {
+		log.Printf("[proxy %s] listener auth disabled", id)
+	}
+	return it.cfg, m.saveState()
+}
+
+// handleV1LocalAuthSet enables listener authentication on a proxy
+// Body (optional): {"user": "...", "pass": "..."}; empty generates credentials
+func (m *Manager) handleV1LocalAuthSet(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		User string `json:"user"`
+		Pass string `json:"pass"`
+	}
+	if r.ContentLength != 0 && !decodeJSON(w, r, &body) {
+		return
+	}
+	up, err := m.setLocalAuth(r.PathValue("id"), true, body.User, body.Pass)
+	if err != nil {
+		writeManagerError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, up)
+}
+
+// handleV1LocalAuthDelete disables listener authentication on a proxy
+func (m *Manager) handleV1LocalAuthDelete(w http.ResponseWriter, r *http.Request) {
+	up, err := m.setLocalAuth(r.PathValue("id"), false, "", "")
+	if err != nil {
+		writeManagerError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, up)
+}
@@ -25,6 +25,10 @@ func init() {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		os.Exit(runImportCommand(os.Args[2:]))
+	}
+
 	// Force local-only binding for UI
 	uiAddr := getenv("UI_ADDR", defaultUIAddr)
 	adminToken := os.Getenv("ADMIN_TOKEN")
@@ -32,6 +36,13 @@ func main() {
 		log.Fatalf("UI_ADDR must bind to 127.0.0.1, got %s", uiAddr)
 	}
 
+	// LAN sharing mode (opt-in): proxy listeners bind on LAN_BIND for
+	// clients in LAN_ALLOW; the UI stays on loopback
+	lan, err := parseLANConfig(os.Getenv("LAN_BIND"), os.Getenv("LAN_ALLOW"), os.Getenv("LAN_ADVERTISE"))
+	if err != nil {
+		log.Fatalf("LAN mode: %v", err)
+	}
+
 	// Firewall protection (default: enabled)
 	enableFirewall := getenv("ENABLE_FIREWALL", "true")
 	if enableFirewall == "true" || enableFirewall == "1" {
@@ -39,6 +50,11 @@ func main() {
 			log.Printf("[Firewall] Error: %v", err)
 			log.Printf("[Firewall] Continuing without firewall protection...")
 		}
+		if lan != nil {
+			if err := createLANInboundRule(lan.Allow); err != nil {
+				log.Printf("[Firewall] Warning: Failed to create LAN inbound rule: %v", err)
+			}
+		}
 	} else {
 		log.Printf("[Firewall] Disabled via ENABLE_FIREWALL=false")
 	}
@@ -48,6 +64,24 @@ func main() {
 
 	m := NewManager(adminToken)
 
+	// require Basic auth on every local listener (credentials generated on start)
+	if v := getenv("LOCAL_AUTH", "false"); v == "true" || v == "1" {
+		m.requireLocalAuth = true
+		log.Printf("[Auth] local listener authentication required")
+	}
+	if lan != nil {
+		m.lan = lan
+		m.requireLocalAuth = true
+		log.Printf("[LAN] listeners bind on %s for clients in %v (advertised as %s); authentication required",
+			lan.Bind, lan.Allow, lan.Advertise)
+	}
+
+	// encrypted provider credentials (secrets.yaml, key in SECRETS_KEY_FILE)
+	secretsPath, secretsKey := secretsPaths()
+	if m.secrets, err = openSecrets(secretsPath, secretsKey); err != nil {
+		log.Fatalf("secrets: %v", err)
+	}
+
 	// load state if exists
 	if err := m.loadState(); err != nil {
 		log.Printf("load state: %v", err)
@@ -55,6 +89,38 @@ func main() {
 		log.Printf("loaded %d proxies from state", len(m.list()))
 	}
 
+	// scheduled state snapshots (BACKUP_INTERVAL=0 disables)
+	if iv := getenv("BACKUP_INTERVAL", backupInterval.String()); iv != "0" {
+		d, err := time.ParseDuration(iv)
+		if err != nil || d <= 0 {
+			log.Printf("invalid BACKUP_INTERVAL %q, using %s", iv, backupInterval)
+			d = backupInterval
+		}
+		go m.backupLoop(d)
+	}
+
+	// hot-reload external edits of the state file (WATCH_STATE=false disables)
+	if w := getenv("WATCH_STATE", "true"); w == "true" || w == "1" {
+		go m.watchState(stateWatchInterval)
+	}
+
+	// periodic remote list syncs (lists with an interval)
+	go m.remoteListLoop(remoteListTick)
+
+	// scheduled provider syncs (tokens from the secrets store, <PROVIDER>_TOKEN or <PROVIDER>_TOKEN_FILE)
+	go m.scheduleLoop(remoteListTick)
+
+	// expiry warnings and optional auto-renew of vendor proxies
+	m.expiry.WarnDays = envDays("EXPIRY_WARN_DAYS", expiryWarnDays)
+	m.expiry.RenewDays = envDays("AUTO_RENEW_DAYS", 0)
+	if m.expiry.RenewDays > 0 {
+		log.Printf("[Expiry] auto-renew for %d days when within %d days of expiry", m.expiry.RenewDays, m.expiry.WarnDays)
+	}
+	go m.expiryLoop(expiryTick)
+
+	// resume vendor orders that were still in progress
+	m.loadJobs()
+
 	// Note: proxies are NOT auto-started on boot
 	// User must manually start them from UI
 
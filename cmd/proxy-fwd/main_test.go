@@ -0,0 +1,22 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// newTestManager returns a Manager whose state file lives in a temporary
+// directory
+func newTestManager(t *testing.T) *Manager {
+	t.Helper()
+	old := stateFile
+	dir := t.TempDir()
+	stateFile = filepath.Join(dir, "proxies.yaml")
+	t.Cleanup(func() { stateFile = old })
+	m := NewManager("")
+	var err error
+	if m.secrets, err = openSecrets(filepath.Join(dir, secretsFileName), filepath.Join(dir, secretsKeyName)); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
@@ -1,10 +1,13 @@
 package main
 
 import (
+	"context"
+	"crypto/sha256"
 	"fmt"
+	"log"
 	"os"
-	"strconv"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -12,81 +15,79 @@ import (
 // NewManager creates a new Manager instance
 func NewManager(adminToken string) *Manager {
 	return &Manager{
-		items:      make(map[string]*ProxyItem),
-		nextPort:   firstLocalPort,
-		adminToken: adminToken,
+		items:          make(map[string]*ProxyItem),
+		pac:            make(map[string]*PACProfile),
+		remote:         make(map[string]*RemoteList),
+		remoteStatus:   make(map[string]*SyncStatus),
+		schedules:      make(map[string]*ProviderSchedule),
+		scheduleStatus: make(map[string]*SyncStatus),
+		jobs:           jobStore{items: make(map[string]*OrderJob), cancel: make(map[string]context.CancelFunc)},
+		expiry:         expiryState{WarnDays: expiryWarnDays, warned: make(map[string]time.Time), renewed: make(map[string]time.Time), noRenew: make(map[string]bool)},
+		nextPort:       firstLocalPort,
+		adminToken:     adminToken,
+		lastBackup:     make(map[string]time.Time),
 	}
 }
 
 // sanitizeID creates a safe ID from host and port
 func sanitizeID(host string, port int) string {
-	s := strings.ReplaceAll(host, ".", "-")
+	s := strings.NewReplacer(".", "-", ":", "-", "[", "", "]", "").Replace(host)
 	return fmt.Sprintf("%s-%d", s, port)
 }
 
-// parseProxyLine parses "ip:port:user:pass" or "ip:port"
-func parseProxyLine(line string) (*Upstream, error) {
-	parts := strings.Split(line, ":")
-	if len(parts) < 2 {
-		return nil, fmt.Errorf("invalid line: %q", line)
-	}
-	host := strings.TrimSpace(parts[0])
-	p, err := strconv.Atoi(strings.TrimSpace(parts[1]))
-	if err != nil {
-		return nil, fmt.Errorf("invalid port in %q: %v", line, err)
-	}
-	up := &Upstream{
-		ID:        sanitizeID(host, p),
-		Host:      host,
-		Port:      p,
-		ProxyType: detectProxyType(host),
-	}
-	if len(parts) >= 4 {
-		up.User = strings.TrimSpace(parts[2])
-		up.Pass = strings.TrimSpace(parts[3])
-	}
-	return up, nil
-}
-
 // loadState loads state from yaml file
 func (m *Manager) loadState() error {
-	fmt.Printf("[LoadState] Reading from: %s\n", stateFile)
+	log.Printf("[LoadState] Reading from: %s", stateFile)
 	b, err := os.ReadFile(stateFile)
 	if err != nil {
 		if os.IsNotExist(err) {
-			fmt.Printf("[LoadState] File not found, starting fresh\n")
+			log.Printf("[LoadState] File not found, starting fresh")
 			return nil
 		}
-		fmt.Printf("[LoadState] Read error: %v\n", err)
+		log.Printf("[LoadState] Read error: %v", err)
 		return err
 	}
-	fmt.Printf("[LoadState] Read %d bytes\n", len(b))
+	log.Printf("[LoadState] Read %d bytes", len(b))
+	m.stateHash = sha256.Sum256(b)
 	var st State
 	if err := yaml.Unmarshal(b, &st); err != nil {
-		fmt.Printf("[LoadState] YAML unmarshal error: %v\n", err)
+		log.Printf("[LoadState] YAML unmarshal error: %v", err)
 		return err
 	}
-	fmt.Printf("[LoadState] Parsed %d items, next port: %d\n", len(st.Items), st.Next)
+	log.Printf("[LoadState] Parsed %d items, next port: %d", len(st.Items), st.Next)
 	if st.Next < firstLocalPort {
 		st.Next = firstLocalPort
 	}
 	m.nextPort = st.Next
+	m.setPACProfilesLocked(st.PACProfiles)
+	moved := m.setRemoteListsLocked(st.RemoteLists)
+	m.setSchedulesLocked(st.Schedules)
+	m.loadGlobalACLLocked(st.ACL)
 	for _, it := range st.Items {
 		// reconstruct item but not running yet
 		m.items[it.ID] = &ProxyItem{cfg: it}
-		fmt.Printf("[LoadState] Loaded: %s (port=%d, status=%s)\n", it.ID, it.LocalPort, it.Status)
+		log.Printf("[LoadState] Loaded: %s (port=%d, status=%s)", it.ID, it.LocalPort, it.Status)
+	}
+	log.Printf("[LoadState] Successfully loaded %d proxies", len(m.items))
+	if moved {
+		// drop the plaintext credentials from proxies.yaml
+		return m.saveState()
 	}
-	fmt.Printf("[LoadState] Successfully loaded %d proxies\n", len(m.items))
 	return nil
 }
 
-// saveState saves state to yaml file
-func (m *Manager) saveState() error {
-	st := State{Next: m.nextPort}
+// marshalState encodes the in-memory state as yaml (must be called with Manager lock held)
+func (m *Manager) marshalState() ([]byte, error) {
+	st := State{Next: m.nextPort, PACProfiles: m.pacProfilesLocked(), RemoteLists: m.remoteListsLocked(), Schedules: m.schedulesLocked(), ACL: m.acl}
 	for _, it := range m.items {
 		st.Items = append(st.Items, it.cfg)
 	}
-	b, err := yaml.Marshal(&st)
+	return yaml.Marshal(&st)
+}
+
+// saveState saves state to yaml file
+func (m *Manager) saveState() error {
+	b, err := m.marshalState()
 	if err != nil {
 		return err
 	}
@@ -94,7 +95,11 @@ func (m *Manager) saveState() error {
 	if err := os.WriteFile(tmp, b, 0644); err != nil {
 		return err
 	}
-	return os.Rename(tmp, stateFile)
+	if err := os.Rename(tmp, stateFile); err != nil {
+		return err
+	}
+	m.stateHash = sha256.Sum256(b)
+	return nil
 }
 
 // allocPort allocates next available port
@@ -129,41 +134,113 @@ func (m *Manager) allocPort() int {
 func (m *Manager) addOrReplace(up *Upstream) (*Upstream, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	up = m.addOrReplaceLocked(up)
+	return up, m.saveState()
+}
+
+// addOrReplaceLocked adds or updates an upstream proxy and assigns it a local
+// port (must be called with Manager lock held, does not save state)
+func (m *Manager) addOrReplaceLocked(up *Upstream) *Upstream {
 	if up.ID == "" {
 		up.ID = sanitizeID(up.Host, up.Port)
 	}
 	if existing, ok := m.items[up.ID]; ok {
-		// replace upstream credentials/host/port but keep local port
+		if len(upstreamDiff(existing.cfg, up)) > 0 {
+			_, _ = m.snapshotLocked("overwrite", false)
+		}
+		// replace upstream credentials/host/port but keep local port, labels and
+		// per-proxy settings the new line does not carry
 		up.LocalPort = existing.cfg.LocalPort
+		if up.Tags == nil {
+			up.Tags = existing.cfg.Tags
+		}
+		if up.Notes == "" {
+			up.Notes = existing.cfg.Notes
+		}
+		if up.LocalUser == "" {
+			up.LocalUser, up.LocalPass = existing.cfg.LocalUser, existing.cfg.LocalPass
+		}
+		if up.HealthURL == "" && up.HealthInterval == 0 && up.HealthFailLimit == 0 {
+			up.HealthURL, up.HealthInterval, up.HealthFailLimit = existing.cfg.HealthURL, existing.cfg.HealthInterval, existing.cfg.HealthFailLimit
+		}
+		if up.Routes == nil {
+			up.Routes = existing.cfg.Routes
+		}
+		if up.ACL == nil {
+			up.ACL = existing.cfg.ACL
+		}
+		if up.Provider == "" {
+			up.Provider, up.VendorID, up.OrderID, up.ExpiresAt = existing.cfg.Provider, existing.cfg.VendorID, existing.cfg.OrderID, existing.cfg.ExpiresAt
+			up.SocksPort, up.VendorStatus = existing.cfg.SocksPort, existing.cfg.VendorStatus
+		}
 		m.items[up.ID].cfg = up
-		return up, m.saveState()
+		return up
 	}
 	up.LocalPort = m.allocPort()
 	up.Status = "creating"
 	m.items[up.ID] = &ProxyItem{cfg: up}
-	return up, m.saveState()
+	return up
 }
 
 // addToPool adds proxy to pool without starting (no local port assigned yet)
 func (m *Manager) addToPool(up *Upstream) (*Upstream, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	up = m.addToPoolLocked(up)
+	return up, m.saveState()
+}
+
+// addToPoolLocked adds proxy to pool or updates credentials of an existing one
+// (must be called with Manager lock held, does not save state)
+func (m *Manager) addToPoolLocked(up *Upstream) *Upstream {
 	if up.ID == "" {
 		up.ID = sanitizeID(up.Host, up.Port)
 	}
 	if existing, ok := m.items[up.ID]; ok {
+		if existing.cfg.User != up.User || existing.cfg.Pass != up.Pass {
+			_, _ = m.snapshotLocked("overwrite", false)
+		}
 		// already exists, just update credentials
 		existing.cfg.Host = up.Host
 		existing.cfg.Port = up.Port
+		existing.cfg.Scheme = up.Scheme
 		existing.cfg.User = up.User
 		existing.cfg.Pass = up.Pass
-		return existing.cfg, m.saveState()
+		return existing.cfg
 	}
 	// Add to pool without local port (will be assigned on start)
 	up.LocalPort = 0
 	up.Status = "stopped"
 	m.items[up.ID] = &ProxyItem{cfg: up}
-	return up, m.saveState()
+	return up
+}
+
+// copyUpstreamConfig copies user-editable configuration from src to dst,
+// leaving runtime fields (local port, status, last error) alone
+func copyUpstreamConfig(dst, src *Upstream) {
+	dst.Host = src.Host
+	dst.Port = src.Port
+	dst.Scheme = src.Scheme
+	dst.User = src.User
+	dst.Pass = src.Pass
+	dst.ProxyType = src.ProxyType
+	dst.Location = src.Location
+	dst.Tags = normalizeTags(src.Tags)
+	dst.Notes = src.Notes
+	dst.HealthURL = src.HealthURL
+	dst.HealthInterval = src.HealthInterval
+	dst.HealthFailLimit = src.HealthFailLimit
+	dst.Routes = src.Routes
+	dst.ACL = src.ACL
+	dst.LocalUser = src.LocalUser
+	dst.LocalPass = src.LocalPass
+	dst.Provider = src.Provider
+	dst.VendorID = src.VendorID
+	dst.OrderID = src.OrderID
+	dst.ExpiresAt = src.ExpiresAt
+	dst.SocksPort = src.SocksPort
+	dst.VendorStatus = src.VendorStatus
+	dst.MissingSince = src.MissingSince
 }
 
 // remove removes a proxy by ID
@@ -174,11 +251,19 @@ func (m *Manager) remove(id string) error {
 	if !ok {
 		return os.ErrNotExist
 	}
+	if _, err := m.snapshotLocked("remove", false); err != nil {
+		log.Printf("[Backup] snapshot before remove: %v", err)
+	}
+	m.removeLocked(it)
+	return m.saveState()
+}
+
+// removeLocked stops and forgets a proxy (must be called with Manager lock held)
+func (m *Manager) removeLocked(it *ProxyItem) {
 	if it.isRunning {
 		_ = m.stopLocked(it)
 	}
-	delete(m.items, id)
-	return m.saveState()
+	delete(m.items, it.cfg.ID)
 }
 
 // start starts a proxy by ID
@@ -189,13 +274,29 @@ func (m *Manager) start(id string) error {
 	if !ok {
 		return os.ErrNotExist
 	}
+	err := m.startFromPoolLocked(it)
+	_ = m.saveState()
+	return err
+}
+
+// startFromPoolLocked assigns a local port if needed and starts the listener
+// (must be called with Manager lock held, does not save state)
+func (m *Manager) startFromPoolLocked(it *ProxyItem) error {
 	if it.isRunning {
 		return nil
 	}
+	if expiredAt(it.cfg, time.Now()) {
+		return invalidf("%s expired at %s", it.cfg.ID, it.cfg.ExpiresAt.Format(time.DateTime))
+	}
+	if it.cfg.MissingSince != nil {
+		return invalidf("%s is no longer listed by %s", it.cfg.ID, it.cfg.Provider)
+	}
+	if vendorOffline(it.cfg.VendorStatus) {
+		return invalidf("%s is reported %s by %s", it.cfg.ID, it.cfg.VendorStatus, it.cfg.Provider)
+	}
 	// Assign local port if not yet assigned (from pool)
 	if it.cfg.LocalPort == 0 {
 		it.cfg.LocalPort = m.allocPort()
-		_ = m.saveState()
 	}
 	return m.startLocked(it)
 }
@@ -223,14 +324,8 @@ func (m *Manager) list() []*Upstream {
 	for _, it := range m.items {
 		res = append(res, it.cfg)
 	}
-	// stable-ish order by LocalPort
-	for i := 0; i < len(res); i++ {
-		for j := i + 1; j < len(res); j++ {
-			if res[j].LocalPort < res[i].LocalPort {
-				res[i], res[j] = res[j], res[i]
-			}
-		}
-	}
+	// stable order by LocalPort, then ID
+	sortUpstreams(res, listSortKeys["local_port"], false)
 	return res
 }
 
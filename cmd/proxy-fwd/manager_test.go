@@ -0,0 +1,40 @@
+package main
+
+import "testing"
+
+// TestReAddKeepsSettings re-adds a proxy from a bare line and checks that the
+// per-proxy settings of the existing entry survive
+func TestReAddKeepsSettings(t *testing.T) {
+	m := newTestManager(t)
+	up, err := parseProxyLine("1.2.3.4:8080:u:p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	up.ACL = &DestACL{Deny: ACLRules{CIDRs: []string{"10.0.0.0/8"}}}
+	up.HealthURL, up.HealthInterval, up.HealthFailLimit = "http://example.com/204", 30, 5
+	up.Routes = []RouteRule{{Hosts: []string{"example.com"}, Action: routeDirect}}
+	if _, err := m.addOrReplace(up); err != nil {
+		t.Fatal(err)
+	}
+
+	again, err := parseProxyLine("1.2.3.4:8080:u:new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := m.addOrReplace(again)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Pass != "new" {
+		t.Errorf("pass = %q, want the new credentials", got.Pass)
+	}
+	if got.HealthURL != "http://example.com/204" || got.HealthInterval != 30 || got.HealthFailLimit != 5 {
+		t.Errorf("health = %q/%d/%d, want the existing overrides", got.HealthURL, got.HealthInterval, got.HealthFailLimit)
+	}
+	if got.ACL == nil || len(got.ACL.Deny.CIDRs) != 1 {
+		t.Errorf("acl = %+v, want the existing ACL", got.ACL)
+	}
+	if len(got.Routes) != 1 || got.Routes[0].Action != routeDirect {
+		t.Errorf("routes = %+v, want the existing routes", got.Routes)
+	}
+}
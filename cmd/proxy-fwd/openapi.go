@@ -0,0 +1,458 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// router is a ServeMux that remembers registered patterns so that the
+// OpenAPI document can be checked against the routes actually served
+type router struct {
+	*http.ServeMux
+	patterns []string
+}
+
+// newRouter creates an empty router
+func newRouter() *router {
+	return &router{ServeMux: http.NewServeMux()}
+}
+
+// HandleFunc registers a handler and records its pattern
+func (rt *router) HandleFunc(pattern string, h func(http.ResponseWriter, *http.Request)) {
+	rt.patterns = append(rt.patterns, pattern)
+	rt.ServeMux.HandleFunc(pattern, h)
+}
+
+// apiParam documents a path or query parameter
+type apiParam struct {
+	Name     string
+	In       string // query|path
+	Desc     string
+	Required bool
+}
+
+// apiDoc documents one operation. Body and Response name a schema from
+// openAPISchemas, or "text" for plain text; an empty Response means 204.
+type apiDoc struct {
+	Method   string
+	Path     string
+	Tag      string
+	Summary  string
+	Params   []apiParam
+	Body     string
+	Response string
+}
+
+var (
+	pID         = apiParam{Name: "id", In: "query", Desc: "Proxy ID", Required: true}
+	pPathID     = apiParam{Name: "id", In: "path", Desc: "Proxy ID", Required: true}
+	pTag        = apiParam{Name: "tag", In: "query", Desc: "Comma separated tags, all required", Required: true}
+	pName       = apiParam{Name: "name", In: "query", Desc: "Snapshot file name", Required: true}
+	pPACName    = apiParam{Name: "name", In: "path", Desc: "PAC profile name", Required: true}
+	pProvider   = apiParam{Name: "name", In: "path", Desc: "Provider name", Required: true}
+	pSecret     = apiParam{Name: "name", In: "path", Desc: "Secret name (the provider name for its token)", Required: true}
+	pVendorID   = apiParam{Name: "vid", In: "path", Desc: "Vendor proxy ID", Required: true}
+	pRemoteName = apiParam{Name: "name", In: "path", Desc: "Remote list name", Required: true}
+	pOrderID    = apiParam{Name: "order_id", In: "query", Desc: "Limit to one order"}
+	pSyncOpts   = []apiParam{
+		{Name: "missing", In: "query", Desc: "keep|mark|remove proxies no longer listed (default mark; keep with order_id)"},
+		{Name: "offline", In: "query", Desc: "flag|skip proxies the vendor reports offline (default flag)"},
+		{Name: "dry_run", In: "query", Desc: "1 = only report the changes"},
+	}
+	pJobID  = apiParam{Name: "id", In: "path", Desc: "Order job ID", Required: true}
+	pImport = []apiParam{
+		{Name: "format", In: "query", Desc: "auto|lines|csv|json|proxifier|switchyomega|clash|singbox (default auto)"},
+		{Name: "name", In: "query", Desc: "Original file name, used to detect the format"},
+		{Name: "dry_run", In: "query", Desc: "1 = only report what would be added, updated or skipped"},
+	}
+	pList = []apiParam{
+		{Name: "status", In: "query", Desc: "Comma separated statuses"},
+		{Name: "type", In: "query", Desc: "Comma separated proxy types"},
+		{Name: "location", In: "query", Desc: "Exact location (case-insensitive)"},
+		{Name: "tag", In: "query", Desc: "Comma separated tags, all required"},
+		{Name: "q", In: "query", Desc: "Search in id, host, user, location, notes and tags"},
+		{Name: "sort", In: "query", Desc: "local_port|id|host|port|status|type|location"},
+		{Name: "order", In: "query", Desc: "asc|desc"},
+		{Name: "limit", In: "query", Desc: "Page size (max 1000, 0 = all)"},
+		{Name: "cursor", In: "query", Desc: "next_cursor of the previous page"},
+	}
+)
+
+// apiDocs documents every route registered in ui()
+var apiDocs = []apiDoc{
+	// v1
+	{Method: "GET", Path: "/api/v1/proxies", Tag: "v1", Summary: "List proxies", Params: pList, Response: "ListResponse"},
+	{Method: "POST", Path: "/api/v1/proxies", Tag: "v1", Summary: "Create a proxy from a line or fields", Body: "CreateRequest", Response: "Upstream"},
+	{Method: "GET", Path: "/api/v1/proxies/{id}", Tag: "v1", Summary: "Get a proxy", Params: []apiParam{pPathID}, Response: "Upstream"},
+	{Method: "PATCH", Path: "/api/v1/proxies/{id}", Tag: "v1", Summary: "Edit a proxy in place (ID and local port are kept)", Params: []apiParam{pPathID}, Body: "UpstreamPatch", Response: "Upstream"},
+	{Method: "DELETE", Path: "/api/v1/proxies/{id}", Tag: "v1", Summary: "Remove a proxy", Params: []apiParam{pPathID}},
+	{Method: "POST", Path: "/api/v1/proxies/{id}/start", Tag: "v1", Summary: "Start a proxy", Params: []apiParam{pPathID}, Response: "Upstream"},
+	{Method: "POST", Path: "/api/v1/proxies/{id}/stop", Tag: "v1", Summary: "Stop a proxy", Params: []apiParam{pPathID}, Response: "Upstream"},
+	{Method: "GET", Path: "/api/v1/proxies/{id}/exit-ip", Tag: "v1", Summary: "Check the exit IP of a running proxy", Params: []apiParam{pPathID}, Response: "ExitIP"},
+	{Method: "POST", Path: "/api/v1/proxies/{id}/local-auth", Tag: "v1", Summary: "Require Basic auth on the local listener (empty body generates credentials)", Params: []apiParam{pPathID}, Body: "LocalAuth", Response: "Upstream"},
+	{Method: "DELETE", Path: "/api/v1/proxies/{id}/local-auth", Tag: "v1", Summary: "Remove local listener auth", Params: []apiParam{pPathID}, Response: "Upstream"},
+	{Method: "POST", Path: "/api/v1/bulk/add", Tag: "v1", Summary: "Add many proxy lines", Body: "BulkAddRequest", Response: "BulkResponse"},
+	{Method: "POST", Path: "/api/v1/bulk/{op}", Tag: "v1", Summary: "Bulk start, stop or remove", Params: []apiParam{{Name: "op", In: "path", Desc: "start|stop|remove", Required: true}}, Body: "BulkTarget", Response: "BulkResponse"},
+	{Method: "GET", Path: "/api/v1/lan", Tag: "v1", Summary: "Listener binding and LAN client allowlist", Response: "LANStatus"},
+	{Method: "GET", Path: "/api/v1/remote-lists", Tag: "providers", Summary: "Remote list providers with their last sync", Response: "RemoteLists"},
+	{Method: "PUT", Path: "/api/v1/remote-lists/{name}", Tag: "providers", Summary: "Create or replace a remote list provider", Params: []apiParam{pRemoteName}, Body: "RemoteList", Response: "RemoteList"},
+	{Method: "DELETE", Path: "/api/v1/remote-lists/{name}", Tag: "providers", Summary: "Remove a remote list provider (pool entries are kept)", Params: []apiParam{pRemoteName}},
+	{Method: "POST", Path: "/api/v1/proxies/{id}/renew", Tag: "providers", Summary: "Renew a proxy at its vendor and refresh its expiry", Params: []apiParam{pPathID, {Name: "days", In: "query", Desc: "Period in days (default 30)"}}, Response: "Upstream"},
+	{Method: "GET", Path: "/api/v1/expiring", Tag: "providers", Summary: "Proxies expiring soon (expired ones included), soonest first", Params: []apiParam{{Name: "days", In: "query", Desc: "Window in days (default EXPIRY_WARN_DAYS)"}}, Response: "Expiring"},
+	{Method: "GET", Path: "/api/v1/schedules", Tag: "providers", Summary: "Scheduled provider syncs with their last outcome", Response: "Schedules"},
+	{Method: "GET", Path: "/api/v1/schedules/{name}", Tag: "providers", Summary: "One scheduled provider sync", Params: []apiParam{pProvider}, Response: "ScheduleStatus"},
+	{Method: "PUT", Path: "/api/v1/schedules/{name}", Tag: "providers", Summary: "Create or replace a scheduled provider sync", Params: []apiParam{pProvider}, Body: "Schedule", Response: "Schedule"},
+	{Method: "DELETE", Path: "/api/v1/schedules/{name}", Tag: "providers", Summary: "Remove a scheduled provider sync", Params: []apiParam{pProvider}},
+	{Method: "POST", Path: "/api/v1/schedules/{name}/run", Tag: "providers", Summary: "Run a scheduled provider sync now", Params: []apiParam{pProvider}, Response: "SyncResult"},
+	{Method: "GET", Path: "/api/v1/secrets", Tag: "providers", Summary: "Stored provider credentials (names only, never values)", Response: "SecretList"},
+	{Method: "PUT", Path: "/api/v1/secrets/{name}", Tag: "providers", Summary: "Store a provider token, encrypted at rest (name = provider)", Params: []apiParam{pSecret}, Body: "SecretValue"},
+	{Method: "DELETE", Path: "/api/v1/secrets/{name}", Tag: "providers", Summary: "Remove a stored provider token", Params: []apiParam{pSecret}},
+	{Method: "GET", Path: "/api/v1/jobs", Tag: "providers", Summary: "Order jobs, newest first", Response: "OrderJobs"},
+	{Method: "POST", Path: "/api/v1/jobs", Tag: "providers", Summary: "Queue a vendor order as a background job (X-Provider-Token header)", Body: "OrderJobRequest", Response: "OrderJob"},
+	{Method: "GET", Path: "/api/v1/jobs/{id}", Tag: "providers", Summary: "Order job progress", Params: []apiParam{pJobID}, Response: "OrderJob"},
+	{Method: "POST", Path: "/api/v1/jobs/{id}/cancel", Tag: "providers", Summary: "Stop an order job (the vendor order is not canceled)", Params: []apiParam{pJobID}, Response: "OrderJob"},
+	{Method: "POST", Path: "/api/v1/remote-lists/{name}/sync", Tag: "providers", Summary: "Fetch a remote list and sync it into the pool now", Params: []apiParam{pRemoteName}, Response: "SyncResult"},
+	{Method: "GET", Path: "/api/v1/acl", Tag: "v1", Summary: "Global destination ACL", Response: "DestACL"},
+	{Method: "PUT", Path: "/api/v1/acl", Tag: "v1", Summary: "Replace the global destination ACL (applies to running ports)", Body: "DestACL", Response: "DestACL"},
+	{Method: "GET", Path: "/api/v1/pac", Tag: "pac", Summary: "List PAC profiles", Response: "PACList"},
+	{Method: "GET", Path: "/api/v1/pac/{name}", Tag: "pac", Summary: "Get a PAC profile", Params: []apiParam{pPACName}, Response: "PACProfile"},
+	{Method: "PUT", Path: "/api/v1/pac/{name}", Tag: "pac", Summary: "Create or replace a PAC profile", Params: []apiParam{pPACName}, Body: "PACProfile", Response: "PACProfile"},
+	{Method: "DELETE", Path: "/api/v1/pac/{name}", Tag: "pac", Summary: "Remove a PAC profile", Params: []apiParam{pPACName}},
+	{Method: "GET", Path: "/pac/{file}", Tag: "pac", Summary: "PAC script of a profile (<name>.pac, ?token= when ADMIN_TOKEN is set)", Params: []apiParam{{Name: "file", In: "path", Desc: "<name>.pac", Required: true}}, Response: "text"},
+	{Method: "POST", Path: "/api/v1/import", Tag: "v1", Summary: "Import a proxy file (raw body)", Params: pImport, Body: "text", Response: "ImportReport"},
+
+	// legacy
+	{Method: "GET", Path: "/api/list", Tag: "legacy", Summary: "List proxies", Params: pList, Response: "ListResponse"},
+	{Method: "POST", Path: "/api/add", Tag: "legacy", Summary: "Add and start a proxy line", Body: "text", Response: "Upstream"},
+	{Method: "POST", Path: "/api/add-pool", Tag: "legacy", Summary: "Add a proxy line to the pool", Body: "text", Response: "Upstream"},
+	{Method: "POST", Path: "/api/remove", Tag: "legacy", Summary: "Remove a proxy", Params: []apiParam{pID}},
+	{Method: "POST", Path: "/api/stop", Tag: "legacy", Summary: "Stop a proxy", Params: []apiParam{pID}},
+	{Method: "POST", Path: "/api/start", Tag: "legacy", Summary: "Start a proxy", Params: []apiParam{pID}},
+	{Method: "GET", Path: "/api/export-local", Tag: "legacy", Summary: "Export running local listeners", Params: append([]apiParam{
+		{Name: "format", In: "query", Desc: "plain|url|csv|pac|proxifier|chrome|clash (default plain)"},
+		{Name: "download", In: "query", Desc: "1 = send as attachment"},
+	}, pList[:5]...), Response: "text"},
+	{Method: "GET", Path: "/api/check-ip", Tag: "legacy", Summary: "Check the exit IP of a running proxy", Params: []apiParam{pID}, Response: "ExitIP"},
+	{Method: "POST", Path: "/api/tags", Tag: "legacy", Summary: "Set tags and notes", Params: []apiParam{pID}, Body: "LabelsRequest", Response: "Upstream"},
+	{Method: "POST", Path: "/api/tag/start", Tag: "legacy", Summary: "Start proxies by tag", Params: []apiParam{pTag}, Response: "BulkResponse"},
+	{Method: "POST", Path: "/api/tag/stop", Tag: "legacy", Summary: "Stop proxies by tag", Params: []apiParam{pTag}, Response: "BulkResponse"},
+	{Method: "POST", Path: "/api/tag/remove", Tag: "legacy", Summary: "Remove proxies by tag", Params: []apiParam{pTag}, Response: "BulkResponse"},
+	{Method: "POST", Path: "/api/bulk/add", Tag: "legacy", Summary: "Add many proxy lines", Body: "BulkAddRequest", Response: "BulkResponse"},
+	{Method: "POST", Path: "/api/bulk/start", Tag: "legacy", Summary: "Bulk start", Body: "BulkTarget", Response: "BulkResponse"},
+	{Method: "POST", Path: "/api/bulk/stop", Tag: "legacy", Summary: "Bulk stop", Body: "BulkTarget", Response: "BulkResponse"},
+	{Method: "POST", Path: "/api/bulk/remove", Tag: "legacy", Summary: "Bulk remove", Body: "BulkTarget", Response: "BulkResponse"},
+	{Method: "POST", Path: "/api/import", Tag: "legacy", Summary: "Import a proxy file (raw body)", Params: pImport, Body: "text", Response: "ImportReport"},
+
+	// backups
+	{Method: "GET", Path: "/api/backups", Tag: "backups", Summary: "List state snapshots", Response: "BackupList"},
+	{Method: "POST", Path: "/api/backups/create", Tag: "backups", Summary: "Take a snapshot now", Response: "BackupName"},
+	{Method: "GET", Path: "/api/backups/diff", Tag: "backups", Summary: "Diff a snapshot against the current state", Params: []apiParam{pName}, Response: "BackupDiff"},
+	{Method: "POST", Path: "/api/backups/restore", Tag: "backups", Summary: "Restore a snapshot", Params: []apiParam{pName}},
+
+	// providers
+	{Method: "GET", Path: "/api/providers", Tag: "providers", Summary: "Registered proxy vendors", Response: "ProviderList"},
+	{Method: "GET", Path: "/api/providers/{name}/regions", Tag: "providers", Summary: "Regions by product type (X-Provider-Token header)", Params: []apiParam{pProvider, {Name: "type", In: "query", Desc: "Product type"}}, Response: "ProviderRegions"},
+	{Method: "POST", Path: "/api/providers/{name}/orders", Tag: "providers", Summary: "Place an order", Params: []apiParam{pProvider}, Body: "OrderRequest", Response: "OrderCreated"},
+	{Method: "GET", Path: "/api/providers/{name}/orders/{order}", Tag: "providers", Summary: "Poll an order", Params: []apiParam{pProvider, {Name: "order", In: "path", Desc: "Order ID", Required: true}}, Response: "OrderStatus"},
+	{Method: "GET", Path: "/api/providers/{name}/proxies", Tag: "providers", Summary: "Proxies of the account or of one order", Params: []apiParam{pProvider, pOrderID}, Response: "ProviderProxies"},
+	{Method: "POST", Path: "/api/providers/{name}/proxies/{vid}/renew", Tag: "providers", Summary: "Renew a proxy at the vendor", Params: []apiParam{pProvider, pVendorID, {Name: "days", In: "query", Desc: "Period in days (default 30)"}}},
+	{Method: "DELETE", Path: "/api/providers/{name}/proxies/{vid}", Tag: "providers", Summary: "Cancel a proxy at the vendor", Params: []apiParam{pProvider, pVendorID}},
+	{Method: "POST", Path: "/api/providers/{name}/sync", Tag: "providers", Summary: "Reconcile the pool with the vendor's proxies", Params: append([]apiParam{pProvider, pOrderID}, pSyncOpts...), Response: "SyncResult"},
+
+	// cloudmini (legacy, uses the cloudmini provider)
+	{Method: "GET", Path: "/api/cloudmini/regions", Tag: "cloudmini", Summary: "CloudMini order regions (X-CloudMini-Token header or stored token)", Params: []apiParam{{Name: "type", In: "query", Desc: "Product type (default proxy-res)"}}, Response: "object"},
+	{Method: "POST", Path: "/api/cloudmini/order", Tag: "cloudmini", Summary: "Queue a CloudMini order job (X-CloudMini-Token header or stored token)", Body: "CloudMiniOrderRequest", Response: "OrderJob"},
+	{Method: "GET", Path: "/api/cloudmini/sync", Tag: "cloudmini", Summary: "Reconcile the pool with CloudMini proxies (X-CloudMini-Token header or stored token)", Params: pSyncOpts, Response: "SyncResult"},
+
+	// system
+	{Method: "GET", Path: "/api/firewall/status", Tag: "system", Summary: "Firewall protection status", Response: "object"},
+	{Method: "GET", Path: "/api/openapi.json", Tag: "system", Summary: "This document", Response: "object"},
+}
+
+// undocumentedPatterns are catch-all routes that are not part of the API
+var undocumentedPatterns = map[string]bool{
+	"/":        true,
+	"/api/v1/": true,
+}
+
+// openAPISchemas are the component schemas referenced by apiDocs
+var openAPISchemas = map[string]any{
+	"Error": schemaObj(map[string]any{
+		"error": schemaObj(map[string]any{"code": schemaString(), "message": schemaString()}),
+	}),
+	"Upstream": schemaObj(map[string]any{
+		"id": schemaString(), "host": schemaString(), "port": schemaInt(), "scheme": schemaString(), "user": schemaString(), "pass": schemaString(),
+		"local_port": schemaInt(), "proxy_type": schemaString(), "location": schemaString(),
+		"tags": schemaArr(schemaString()), "notes": schemaString(), "status": schemaString(), "last_error": schemaString(),
+		"health_url": schemaString(), "health_interval": schemaInt(), "health_fail_limit": schemaInt(),
+		"routes": schemaArr(schemaRef("RouteRule")), "acl": schemaRef("DestACL"),
+		"local_user": schemaString(), "local_pass": schemaString(),
+		"provider": schemaString(), "vendor_id": schemaString(), "order_id": schemaString(), "expires_at": schemaString(),
+		"socks_port": schemaInt(), "vendor_status": schemaString(), "missing_since": schemaString(),
+	}),
+	"LANStatus": schemaObj(map[string]any{
+		"enabled": schemaBool(), "bind": schemaString(), "advertise": schemaString(), "allow": schemaArr(schemaString()),
+	}),
+	"LocalAuth": schemaObj(map[string]any{"user": schemaString(), "pass": schemaString()}),
+	"UpstreamPatch": schemaObj(map[string]any{
+		"host": schemaString(), "port": schemaInt(), "scheme": schemaString(), "user": schemaString(), "pass": schemaString(),
+		"proxy_type": schemaString(), "location": schemaString(), "tags": schemaArr(schemaString()), "notes": schemaString(),
+		"health_url": schemaString(), "health_interval": schemaInt(), "health_fail_limit": schemaInt(),
+		"routes": schemaArr(schemaRef("RouteRule")), "acl": schemaRef("DestACL"),
+	}),
+	"ACLRules": schemaObj(map[string]any{
+		"domains": schemaArr(schemaString()), "cidrs": schemaArr(schemaString()), "ports": schemaArr(schemaString()),
+	}),
+	"DestACL": schemaObj(map[string]any{"allow": schemaRef("ACLRules"), "deny": schemaRef("ACLRules")}),
+	"RouteRule": schemaObj(map[string]any{
+		"hosts": schemaArr(schemaString()), "regex": schemaString(), "ports": schemaArr(schemaInt()),
+		"action": schemaString(), "via": schemaString(),
+	}),
+	"ListResponse": schemaObj(map[string]any{
+		"items": schemaArr(schemaRef("Upstream")), "total": schemaInt(), "next_cursor": schemaString(),
+	}),
+	"CreateRequest": schemaObj(map[string]any{
+		"line": schemaString(), "host": schemaString(), "port": schemaInt(), "scheme": schemaString(), "user": schemaString(), "pass": schemaString(),
+		"proxy_type": schemaString(), "location": schemaString(), "tags": schemaArr(schemaString()), "notes": schemaString(), "start": schemaBool(),
+	}),
+	"LabelsRequest": schemaObj(map[string]any{"tags": schemaArr(schemaString()), "notes": schemaString()}),
+	"ExitIP":        schemaObj(map[string]any{"ip": schemaString()}),
+	"BulkAddRequest": schemaObj(map[string]any{
+		"lines": schemaArr(schemaString()), "text": schemaString(), "start": schemaBool(),
+	}),
+	"BulkTarget": schemaObj(map[string]any{
+		"ids": schemaArr(schemaString()),
+		"selector": schemaObj(map[string]any{
+			"status": schemaString(), "type": schemaString(), "location": schemaString(), "tag": schemaString(), "q": schemaString(),
+		}),
+	}),
+	"BulkResponse": schemaObj(map[string]any{
+		"ok": schemaInt(), "failed": schemaInt(),
+		"results": schemaArr(schemaObj(map[string]any{
+			"id": schemaString(), "line": schemaInt(), "ok": schemaBool(), "error": schemaString(), "item": schemaRef("Upstream"),
+		})),
+	}),
+	"PACProfile": schemaObj(map[string]any{
+		"name": schemaString(), "default": schemaString(),
+		"rules": schemaArr(schemaObj(map[string]any{"match": schemaArr(schemaString()), "target": schemaString()})),
+	}),
+	"PACList": schemaObj(map[string]any{"items": schemaArr(schemaRef("PACProfile"))}),
+	"ImportReport": schemaObj(map[string]any{
+		"format": schemaString(), "dry_run": schemaBool(),
+		"added": schemaInt(), "updated": schemaInt(), "skipped": schemaInt(), "failed": schemaInt(),
+		"results": schemaArr(schemaObj(map[string]any{
+			"source": schemaString(), "id": schemaString(), "action": schemaString(),
+			"changes": schemaArr(schemaString()), "reason": schemaString(), "item": schemaRef("Upstream"),
+		})),
+	}),
+	"BackupList": schemaObj(map[string]any{
+		"items": schemaArr(schemaObj(map[string]any{
+			"name": schemaString(), "reason": schemaString(), "created_at": schemaString(), "size": schemaInt(), "items": schemaInt(),
+		})),
+	}),
+	"BackupName": schemaObj(map[string]any{"name": schemaString()}),
+	"BackupDiff": schemaObj(map[string]any{
+		"name": schemaString(), "added": schemaArr(schemaString()), "removed": schemaArr(schemaString()),
+		"changed": map[string]any{"type": "object", "additionalProperties": schemaArr(schemaString())},
+	}),
+	"CloudMiniOrderRequest": schemaObj(map[string]any{
+		"type": schemaString(), "region": schemaString(), "quantity": schemaInt(), "start": schemaBool(),
+	}),
+	"ProviderList": schemaObj(map[string]any{"items": schemaArr(schemaString())}),
+	"ProviderRegions": schemaObj(map[string]any{"items": schemaArr(schemaObj(map[string]any{
+		"type": schemaString(), "regions": schemaArr(schemaString()),
+	}))}),
+	"OrderRequest": schemaObj(map[string]any{
+		"type": schemaString(), "region": schemaString(), "quantity": schemaInt(),
+	}),
+	"OrderCreated": schemaObj(map[string]any{"order_id": schemaString()}),
+	"OrderStatus": schemaObj(map[string]any{
+		"id": schemaString(), "status": schemaString(), "done": schemaBool(), "failed": schemaBool(),
+	}),
+	"ProviderProxy": schemaObj(map[string]any{
+		"vendor_id": schemaString(), "host": schemaString(), "port": schemaInt(), "user": schemaString(), "pass": schemaString(),
+		"scheme": schemaString(), "proxy_type": schemaString(), "location": schemaString(), "status": schemaString(),
+		"order_id": schemaString(), "expires_at": schemaString(), "socks_port": schemaInt(),
+	}),
+	"ProviderProxies": schemaObj(map[string]any{"items": schemaArr(schemaRef("ProviderProxy"))}),
+	"SyncResult": schemaObj(map[string]any{
+		"total": schemaInt(), "added": schemaInt(), "existing": schemaInt(), "updated": schemaInt(),
+		"restored": schemaInt(), "missing": schemaInt(), "removed": schemaInt(), "offline": schemaInt(), "skipped": schemaInt(),
+		"dry_run": schemaBool(),
+		"changes": schemaArr(schemaObj(map[string]any{
+			"id": schemaString(), "vendor_id": schemaString(), "action": schemaString(), "fields": schemaArr(schemaString()),
+		})),
+		"errors": schemaArr(schemaString()),
+	}),
+	"RemoteList": schemaObj(map[string]any{
+		"name": schemaString(), "url": schemaString(), "headers": schemaObj(map[string]any{}), "format": schemaString(),
+		"items": schemaString(), "fields": schemaObj(map[string]any{}), "interval": schemaInt(), "secret_ref": schemaString(),
+	}),
+	"Expiring": schemaObj(map[string]any{
+		"days": schemaInt(), "auto_renew_days": schemaInt(), "items": schemaArr(schemaObj(map[string]any{
+			"id": schemaString(), "provider": schemaString(), "vendor_id": schemaString(), "order_id": schemaString(),
+			"status": schemaString(), "expires_at": schemaString(), "days_left": schemaInt(),
+		})),
+	}),
+	"Schedule": schemaObj(map[string]any{
+		"provider": schemaString(), "interval": schemaInt(), "missing": schemaString(), "offline": schemaString(),
+	}),
+	"ScheduleStatus": schemaObj(map[string]any{
+		"provider": schemaString(), "interval": schemaInt(), "missing": schemaString(), "offline": schemaString(),
+		"token_configured": schemaBool(), "next_sync": schemaString(),
+		"status": schemaObj(map[string]any{"last_sync": schemaString(), "last_error": schemaString(), "result": schemaRef("SyncResult")}),
+	}),
+	"Schedules": schemaObj(map[string]any{"items": schemaArr(schemaRef("ScheduleStatus"))}),
+	"SecretList": schemaObj(map[string]any{
+		"items": schemaArr(schemaObj(map[string]any{"name": schemaString(), "updated": schemaString()})),
+	}),
+	"SecretValue": schemaObj(map[string]any{"value": schemaString()}),
+	"OrderJobRequest": schemaObj(map[string]any{
+		"provider": schemaString(), "type": schemaString(), "region": schemaString(), "quantity": schemaInt(), "start": schemaBool(),
+	}),
+	"OrderJob": schemaObj(map[string]any{
+		"id": schemaString(), "provider": schemaString(), "request": schemaRef("OrderRequest"), "start": schemaBool(),
+		"status": schemaString(), "orders": schemaArr(schemaRef("JobOrder")),
+		"error": schemaString(), "result": schemaRef("SyncResult"), "proxy_ids": schemaArr(schemaString()),
+		"created": schemaString(), "updated": schemaString(),
+	}),
+	"OrderJobs":   schemaObj(map[string]any{"items": schemaArr(schemaRef("OrderJob"))}),
+	"RemoteLists": schemaObj(map[string]any{"items": schemaArr(schemaRef("RemoteList"))}),
+}
+
+func schemaObj(props map[string]any) map[string]any {
+	return map[string]any{"type": "object", "properties": props}
+}
+func schemaArr(items any) map[string]any { return map[string]any{"type": "array", "items": items} }
+func schemaRef(name string) map[string]any {
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
+func schemaString() map[string]any { return map[string]any{"type": "string"} }
+func schemaInt() map[string]any    { return map[string]any{"type": "integer"} }
+func schemaBool() map[string]any   { return map[string]any{"type": "boolean"} }
+
+// mediaFor returns the content map for a schema name
+func mediaFor(schema string) map[string]any {
+	switch schema {
+	case "text":
+		return map[string]any{"text/plain": map[string]any{"schema": schemaString()}}
+	case "object":
+		return map[string]any{"application/json": map[string]any{"schema": map[string]any{"type": "object"}}}
+	}
+	return map[string]any{"application/json": map[string]any{"schema": schemaRef(schema)}}
+}
+
+// openAPIDocument builds the OpenAPI 3.0 document from apiDocs
+func openAPIDocument() map[string]any {
+	paths := map[string]any{}
+	errResp := map[string]any{"description": "Error", "content": mediaFor("Error")}
+	for _, d := range apiDocs {
+		op := map[string]any{
+			"summary":     d.Summary,
+			"tags":        []string{d.Tag},
+			"operationId": operationID(d),
+		}
+		var params []map[string]any
+		for _, p := range d.Params {
+			params = append(params, map[string]any{
+				"name": p.Name, "in": p.In, "description": p.Desc,
+				"required": p.Required, "schema": schemaString(),
+			})
+		}
+		if params != nil {
+			op["parameters"] = params
+		}
+		if d.Body != "" {
+			op["requestBody"] = map[string]any{"required": true, "content": mediaFor(d.Body)}
+		}
+		responses := map[string]any{"default": errResp}
+		if d.Response == "" {
+			responses["204"] = map[string]any{"description": "No Content"}
+		} else {
+			responses["200"] = map[string]any{"description": "OK", "content": mediaFor(d.Response)}
+		}
+		op["responses"] = responses
+
+		item, _ := paths[d.Path].(map[string]any)
+		if item == nil {
+			item = map[string]any{}
+			paths[d.Path] = item
+		}
+		item[strings.ToLower(d.Method)] = op
+	}
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "Proxy Forward API",
+			"version": "1.0.0",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": openAPISchemas,
+			"securitySchemes": map[string]any{
+				"adminToken": map[string]any{"type": "apiKey", "in": "header", "name": "X-Admin-Token"},
+			},
+		},
+		"security": []map[string]any{{"adminToken": []string{}}},
+	}
+}
+
+// operationID derives a stable operationId such as "get_api_v1_proxies_id"
+func operationID(d apiDoc) string {
+	r := strings.NewReplacer("/", "_", "{", "", "}", "", "-", "_", ".", "_")
+	return strings.ToLower(d.Method) + strings.TrimRight(r.Replace(d.Path), "_")
+}
+
+// undocumentedRoutes returns registered patterns missing from apiDocs.
+// Patterns without a method ("/api/list") match a document with any method.
+func undocumentedRoutes(patterns []string) []string {
+	var missing []string
+	for _, p := range patterns {
+		if undocumentedPatterns[p] {
+			continue
+		}
+		method, path := "", p
+		if i := strings.IndexByte(p, ' '); i > 0 {
+			method, path = p[:i], p[i+1:]
+		}
+		found := false
+		for _, d := range apiDocs {
+			if d.Path == path && (method == "" || d.Method == method) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, p)
+		}
+	}
+	return missing
+}
+
+// staleAPIDocs returns documented operations that no registered pattern serves
+func staleAPIDocs(patterns []string) []string {
+	served := make(map[string]bool, len(patterns))
+	for _, p := range patterns {
+		served[p] = true
+	}
+	var stale []string
+	for _, d := range apiDocs {
+		if !served[d.Method+" "+d.Path] && !served[d.Path] {
+			stale = append(stale, d.Method+" "+d.Path)
+		}
+	}
+	return stale
+}
+
+// handleOpenAPI serves the OpenAPI document
+func (m *Manager) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if !m.handleAuth(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(openAPIDocument())
+}
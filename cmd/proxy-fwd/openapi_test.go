@@ -0,0 +1,42 @@
+package main
+
+import "testing"
+
+// testRoutes returns the patterns registered by the UI/API router
+func testRoutes(t *testing.T) []string {
+	t.Helper()
+	rt, ok := NewManager("").ui().(*router)
+	if !ok {
+		t.Fatal("ui() does not return a *router")
+	}
+	if len(rt.patterns) == 0 {
+		t.Fatal("no routes registered")
+	}
+	return rt.patterns
+}
+
+func TestEveryRouteIsDocumented(t *testing.T) {
+	for _, p := range undocumentedRoutes(testRoutes(t)) {
+		t.Errorf("route %q is not documented in apiDocs", p)
+	}
+}
+
+func TestEveryDocumentedOperationIsServed(t *testing.T) {
+	for _, d := range staleAPIDocs(testRoutes(t)) {
+		t.Errorf("%q is documented in apiDocs but no route serves it", d)
+	}
+}
+
+func TestStaleAPIDocsDetectsMissingRoute(t *testing.T) {
+	patterns := testRoutes(t)
+	var without []string
+	for _, p := range patterns {
+		if p != "GET /api/v1/proxies" {
+			without = append(without, p)
+		}
+	}
+	stale := staleAPIDocs(without)
+	if len(stale) != 1 || stale[0] != "GET /api/v1/proxies" {
+		t.Fatalf("staleAPIDocs = %v, want [GET /api/v1/proxies]", stale)
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PACProfile maps domain patterns to local listeners for one browser profile
+type PACProfile struct {
+	Name    string    `yaml:"name" json:"name"`
+	Rules   []PACRule `yaml:"rules" json:"rules"`                         // first match wins
+	Default string    `yaml:"default,omitempty" json:"default,omitempty"` // target for unmatched hosts, "" = DIRECT
+}
+
+// PACRule routes hosts matching any pattern to a target. Patterns are domain
+// suffixes ("bank.com" matches bank.com and *.bank.com) or shExpMatch globs
+// ("*.bank.*"). Target is DIRECT, a proxy ID or a local port number.
+type PACRule struct {
+	Match  []string `yaml:"match" json:"match"`
+	Target string   `yaml:"target" json:"target"`
+}
+
+// pacDirect is the target meaning "no proxy"
+const pacDirect = "DIRECT"
+
+// pacUnavailable is returned for proxies that are not running so that
+// traffic fails instead of silently going DIRECT
+const pacUnavailable = "PROXY 127.0.0.1:0"
+
+// validatePACLocked checks a profile (must be called with Manager lock held)
+func (m *Manager) validatePACLocked(p *PACProfile) error {
+	if !nameRe.MatchString(p.Name) {
+		return invalidf("invalid profile name %q (letters, digits, '-' and '_')", p.Name)
+	}
+	check := func(target string) error {
+		if target == "" || strings.EqualFold(target, pacDirect) {
+			return nil
+		}
+		if n, err := strconv.Atoi(target); err == nil {
+			if n < 1 || n > 65535 {
+				return invalidf("invalid port target %q", target)
+			}
+			return nil
+		}
+		if _, ok := m.items[target]; !ok {
+			return invalidf("unknown proxy %q", target)
+		}
+		return nil
+	}
+	for i := range p.Rules {
+		r := &p.Rules[i]
+		var pats []string
+		for _, pat := range r.Match {
+			if pat = strings.ToLower(strings.TrimSpace(pat)); pat != "" {
+				pats = append(pats, strings.TrimPrefix(pat, "."))
+			}
+		}
+		if len(pats) == 0 {
+			return invalidf("rule %d: match required", i+1)
+		}
+		r.Match = pats
+		if err := check(r.Target); err != nil {
+			return invalidf("rule %d: %v", i+1, err)
+		}
+	}
+	return check(p.Default)
+}
+
+// pacTargetLocked resolves a rule target to a PAC return value
+func (m *Manager) pacTargetLocked(target string) string {
+	if target == "" || strings.EqualFold(target, pacDirect) {
+		return pacDirect
+	}
+	if n, err := strconv.Atoi(target); err == nil {
+		return "PROXY " + net.JoinHostPort(m.clientHost(), strconv.Itoa(n))
+	}
+	if it, ok := m.items[target]; ok && it.isRunning && it.cfg.LocalPort > 0 {
+		return "PROXY " + net.JoinHostPort(m.clientHost(), strconv.Itoa(it.cfg.LocalPort))
+	}
+	return pacUnavailable
+}
+
+// pacScript renders the PAC script of a profile with current local ports
+func (m *Manager) pacScript(name string) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	p, ok := m.pac[name]
+	if !ok {
+		return "", os.ErrNotExist
+	}
+	var b strings.Builder
+	b.WriteString("// proxy-fwd PAC profile " + p.Name + "\n")
+	b.WriteString("function proxyFwdMatch(host, pats) {\n" +
+		"  for (var i = 0; i < pats.length; i++) {\n" +
+		"    var p = pats[i];\n" +
+		"    if (p.indexOf(\"*\") >= 0 || p.indexOf(\"?\") >= 0) {\n" +
+		"      if (shExpMatch(host, p)) return true;\n" +
+		"    } else if (host === p || dnsDomainIs(host, \".\" + p)) {\n" +
+		"      return true;\n" +
+		"    }\n" +
+		"  }\n" +
+		"  return false;\n" +
+		"}\n\n")
+	b.WriteString("function FindProxyForURL(url, host) {\n  host = host.toLowerCase();\n")
+	for _, r := range p.Rules {
+		pats, _ := json.Marshal(r.Match)
+		fmt.Fprintf(&b, "  if (proxyFwdMatch(host, %s)) return %s;\n", pats, strconv.Quote(m.pacTargetLocked(r.Target)))
+	}
+	fmt.Fprintf(&b, "  return %s;\n}\n", strconv.Quote(m.pacTargetLocked(p.Default)))
+	return b.String(), nil
+}
+
+// pacProfiles returns all profiles ordered by name
+func (m *Manager) pacProfiles() []*PACProfile {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.pacProfilesLocked()
+}
+
+func (m *Manager) pacProfilesLocked() []*PACProfile {
+	res := make([]*PACProfile, 0, len(m.pac))
+	for _, p := range m.pac {
+		res = append(res, p)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res
+}
+
+// setPACProfilesLocked replaces all profiles (used when loading state)
+func (m *Manager) setPACProfilesLocked(ps []*PACProfile) {
+	m.pac = make(map[string]*PACProfile, len(ps))
+	for _, p := range ps {
+		if p != nil && p.Name != "" {
+			m.pac[p.Name] = p
+		}
+	}
+}
+
+// putPACProfile creates or replaces a profile
+func (m *Manager) putPACProfile(p *PACProfile) (*PACProfile, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.validatePACLocked(p); err != nil {
+		return nil, err
+	}
+	if _, ok := m.pac[p.Name]; ok {
+		_, _ = m.snapshotLocked("pac", false)
+	}
+	m.pac[p.Name] = p
+	log.Printf("[PAC] profile %s saved (%d rules)", p.Name, len(p.Rules))
+	return p, m.saveState()
+}
+
+// deletePACProfile removes a profile
+func (m *Manager) deletePACProfile(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.pac[name]; !ok {
+		return os.ErrNotExist
+	}
+	_, _ = m.snapshotLocked("pac", false)
+	delete(m.pac, name)
+	return m.saveState()
+}
+
+// handleV1PACList lists PAC profiles
+func (m *Manager) handleV1PACList(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{"items": m.pacProfiles()})
+}
+
+// handleV1PACGet returns one PAC profile
+func (m *Manager) handleV1PACGet(w http.ResponseWriter, r *http.Request) {
+	m.mu.RLock()
+	p, ok := m.pac[r.PathValue("name")]
+	m.mu.RUnlock()
+	if !ok {
+		writeAPIError(w, http.StatusNotFound, codeNotFound, "profile not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, p)
+}
+
+// handleV1PACPut creates or replaces a PAC profile
+// Body: {"rules": [{"match": ["vcb.com.vn"], "target": "<proxy id>"}], "default": "DIRECT"}
+func (m *Manager) handleV1PACPut(w http.ResponseWriter, r *http.Request) {
+	var p PACProfile
+	if !decodeJSON(w, r, &p) {
+		return
+	}
+	p.Name = r.PathValue("name")
+	res, err := m.putPACProfile(&p)
+	if err != nil {
+		writeManagerError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// handleV1PACDelete removes a PAC profile
+func (m *Manager) handleV1PACDelete(w http.ResponseWriter, r *http.Request) {
+	if err := m.deletePACProfile(r.PathValue("name")); err != nil {
+		if os.IsNotExist(err) {
+			writeAPIError(w, http.StatusNotFound, codeNotFound, "profile not found")
+			return
+		}
+		writeManagerError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlePACScript serves /pac/<name>.pac; browsers cannot send headers for a
+// PAC URL, so with ADMIN_TOKEN set use /pac/<name>.pac?token=<token>
+func (m *Manager) handlePACScript(w http.ResponseWriter, r *http.Request) {
+	if !m.handleAuth(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	name, ok := strings.CutSuffix(r.PathValue("file"), ".pac")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	script, err := m.pacScript(name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-ns-proxy-autoconfig")
+	// local ports change when proxies restart
+	w.Header().Set("Cache-Control", "no-cache")
+	fmt.Fprint(w, script)
+}
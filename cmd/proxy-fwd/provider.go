@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProviderRegion lists the regions a vendor sells for one product type
+type ProviderRegion struct {
+	Type    string   `json:"type"`
+	Regions []string `json:"regions"`
+}
+
+// OrderRequest is a purchase from a vendor
+type OrderRequest struct {
+	Type     string `json:"type"`
+	Region   string `json:"region"`
+	Quantity int    `json:"quantity"`
+}
+
+// OrderStatus is the vendor-side state of an order
+type OrderStatus struct {
+	ID     string `json:"id"`
+	Status string `json:"status"` // vendor wording
+	Done   bool   `json:"done"`   // proxies can be listed
+	Failed bool   `json:"failed"` // the order will never complete
+}
+
+// ProviderProxy is a proxy as listed by a vendor
+type ProviderProxy struct {
+	VendorID  string     `json:"vendor_id,omitempty"` // vendor primary key, used to renew or delete
+	Host      string     `json:"host"`
+	Port      int        `json:"port"`
+	User      string     `json:"user"`
+	Pass      string     `json:"pass"`
+	Scheme    string     `json:"scheme,omitempty"`
+	ProxyType string     `json:"proxy_type,omitempty"`
+	Location  string     `json:"location,omitempty"`
+	Status    string     `json:"status,omitempty"` // vendor wording
+	OrderID   string     `json:"order_id,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	SocksPort int        `json:"socks_port,omitempty"` // alternate SOCKS5 endpoint on the same host
+}
+
+// upstream converts a vendor proxy into a pool entry
+func (p *ProviderProxy) upstream() *Upstream {
+	pt := p.ProxyType
+	if pt == "" {
+		pt = detectProxyType(p.Host)
+	}
+	return &Upstream{
+		ID:           sanitizeID(p.Host, p.Port),
+		Host:         p.Host,
+		Port:         p.Port,
+		User:         p.User,
+		Pass:         p.Pass,
+		Scheme:       p.Scheme,
+		ProxyType:    pt,
+		Location:     p.Location,
+		VendorID:     p.VendorID,
+		OrderID:      p.OrderID,
+		ExpiresAt:    p.ExpiresAt,
+		SocksPort:    p.SocksPort,
+		VendorStatus: p.Status,
+		Status:       "stopped",
+	}
+}
+
+// Provider is a proxy vendor. token is the account credential for the
+// vendor API; operations a vendor does not offer return errUnsupported.
+type Provider interface {
+	Name() string
+	Regions(ctx context.Context, token, proxyType string) ([]ProviderRegion, error)
+	Order(ctx context.Context, token string, req OrderRequest) (orderID string, err error)
+	OrderStatus(ctx context.Context, token, orderID string) (OrderStatus, error)
+	// Proxies lists the proxies of one order, or of the account when orderID is ""
+	Proxies(ctx context.Context, token, orderID string) ([]ProviderProxy, error)
+	Renew(ctx context.Context, token, vendorID string, days int) error
+	Delete(ctx context.Context, token, vendorID string) error
+}
+
+var errUnsupported = errors.New("not supported by this provider")
+
+// providers is the registry of vendors by name
+var providers = struct {
+	sync.RWMutex
+	m map[string]Provider
+}{m: make(map[string]Provider)}
+
+// registerProvider adds or replaces a provider
+func registerProvider(p Provider) {
+	providers.Lock()
+	providers.m[p.Name()] = p
+	providers.Unlock()
+}
+
+// unregisterProvider removes a provider
+func unregisterProvider(name string) {
+	providers.Lock()
+	delete(providers.m, name)
+	providers.Unlock()
+}
+
+// getProvider looks up a provider by name
+func getProvider(name string) (Provider, bool) {
+	providers.RLock()
+	defer providers.RUnlock()
+	p, ok := providers.m[name]
+	return p, ok
+}
+
+// providerNames returns the registered provider names in order
+func providerNames() []string {
+	providers.RLock()
+	defer providers.RUnlock()
+	names := make([]string, 0, len(providers.m))
+	for n := range providers.m {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// configuredToken is the server-side token of a provider: the secret named
+// after it, else the <NAME>_TOKEN environment variable (e.g. CLOUDMINI_TOKEN)
+// or the file named by <NAME>_TOKEN_FILE
+func (m *Manager) configuredToken(provider string) string {
+	if t := m.secrets.get(provider); t != "" {
+		return t
+	}
+	key := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' {
+			return r - 'a' + 'A'
+		}
+		if r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, provider)
+	if t := os.Getenv(key + "_TOKEN"); t != "" {
+		return t
+	}
+	if f := os.Getenv(key + "_TOKEN_FILE"); f != "" {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			log.Printf("[Providers] %s_TOKEN_FILE: %v", key, err)
+			return ""
+		}
+		return strings.TrimSpace(string(b))
+	}
+	return ""
+}
+
+// providerToken is the vendor credential of a provider request: the
+// X-Provider-Token header, else the server-side token
+func (m *Manager) providerToken(r *http.Request, provider string) string {
+	if t := r.Header.Get("X-Provider-Token"); t != "" {
+		return t
+	}
+	return m.configuredToken(provider)
+}
+
+// writeProviderError maps provider failures: validation to 400, the rest to 502
+func writeProviderError(w http.ResponseWriter, err error) {
+	var invalid *invalidError
+	switch {
+	case errors.As(err, &invalid):
+		writeAPIError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+	case errors.Is(err, errUnsupported):
+		writeAPIError(w, http.StatusNotImplemented, codeBadRequest, err.Error())
+	default:
+		writeAPIError(w, http.StatusBadGateway, codeUpstream, err.Error())
+	}
+}
+
+// provider wraps a /api/providers/{name} handler with auth and provider lookup
+func (m *Manager) provider(h func(w http.ResponseWriter, r *http.Request, p Provider)) http.HandlerFunc {
+	return m.v1(func(w http.ResponseWriter, r *http.Request) {
+		p, ok := getProvider(r.PathValue("name"))
+		if !ok {
+			writeAPIError(w, http.StatusNotFound, codeNotFound, "unknown provider "+r.PathValue("name"))
+			return
+		}
+		h(w, r, p)
+	})
+}
+
+// registerProviders adds the generic /api/providers routes to mux
+func (m *Manager) registerProviders(mux *router) {
+	mux.HandleFunc("GET /api/providers", m.v1(m.handleProviderList))
+	mux.HandleFunc("GET /api/providers/{name}/regions", m.provider(m.handleProviderRegions))
+	mux.HandleFunc("POST /api/providers/{name}/orders", m.provider(m.handleProviderOrder))
+	mux.HandleFunc("GET /api/providers/{name}/orders/{order}", m.provider(m.handleProviderOrderStatus))
+	mux.HandleFunc("GET /api/providers/{name}/proxies", m.provider(m.handleProviderProxies))
+	mux.HandleFunc("POST /api/providers/{name}/proxies/{vid}/renew", m.provider(m.handleProviderRenew))
+	mux.HandleFunc("DELETE /api/providers/{name}/proxies/{vid}", m.provider(m.handleProviderDelete))
+	mux.HandleFunc("POST /api/providers/{name}/sync", m.provider(m.handleProviderSync))
+}
+
+// handleProviderList lists the registered providers
+func (m *Manager) handleProviderList(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{"items": providerNames()})
+}
+
+// handleProviderRegions lists regions; ?type= selects the product type
+func (m *Manager) handleProviderRegions(w http.ResponseWriter, r *http.Request, p Provider) {
+	regions, err := p.Regions(r.Context(), m.providerToken(r, p.Name()), r.URL.Query().Get("type"))
+	if err != nil {
+		writeProviderError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"items": regions})
+}
+
+// handleProviderOrder places an order and returns its ID
+// Body: {"type": "proxy-res", "region": "VN", "quantity": 1}
+func (m *Manager) handleProviderOrder(w http.ResponseWriter, r *http.Request, p Provider) {
+	var req OrderRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.Type == "" || req.Region == "" {
+		writeAPIError(w, http.StatusBadRequest, codeBadRequest, "type and region required")
+		return
+	}
+	id, err := p.Order(r.Context(), m.providerToken(r, p.Name()), req)
+	if err != nil {
+		writeProviderError(w, err)
+		return
+	}
+	log.Printf("[%s Order] Created order_id: %s", p.Name(), id)
+	writeJSON(w, http.StatusCreated, map[string]string{"order_id": id})
+}
+
+// handleProviderOrderStatus polls an order once
+func (m *Manager) handleProviderOrderStatus(w http.ResponseWriter, r *http.Request, p Provider) {
+	st, err := p.OrderStatus(r.Context(), m.providerToken(r, p.Name()), r.PathValue("order"))
+	if err != nil {
+		writeProviderError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, st)
+}
+
+// handleProviderProxies lists the account's proxies, or one order's with ?order_id=
+func (m *Manager) handleProviderProxies(w http.ResponseWriter, r *http.Request, p Provider) {
+	ps, err := p.Proxies(r.Context(), m.providerToken(r, p.Name()), r.URL.Query().Get("order_id"))
+	if err != nil {
+		writeProviderError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"items": ps})
+}
+
+// handleProviderRenew extends a proxy; ?days= defaults to 30
+func (m *Manager) handleProviderRenew(w http.ResponseWriter, r *http.Request, p Provider) {
+	days := 30
+	if s := r.URL.Query().Get("days"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			writeAPIError(w, http.StatusBadRequest, codeBadRequest, "invalid days")
+			return
+		}
+		days = n
+	}
+	if err := p.Renew(r.Context(), m.providerToken(r, p.Name()), r.PathValue("vid"), days); err != nil {
+		writeProviderError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleProviderDelete cancels a proxy at the vendor (the pool entry is kept)
+func (m *Manager) handleProviderDelete(w http.ResponseWriter, r *http.Request, p Provider) {
+	if err := p.Delete(r.Context(), m.providerToken(r, p.Name()), r.PathValue("vid")); err != nil {
+		writeProviderError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleProviderSync reconciles the pool with the account's proxies (or adds
+// one order's with ?order_id=); ?missing= and ?dry_run= see parseSyncOptions
+func (m *Manager) handleProviderSync(w http.ResponseWriter, r *http.Request, p Provider) {
+	opt, err := parseSyncOptions(r)
+	if err != nil {
+		writeProviderError(w, err)
+		return
+	}
+	ps, err := p.Proxies(r.Context(), m.providerToken(r, p.Name()), r.URL.Query().Get("order_id"))
+	if err != nil {
+		writeProviderError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, m.syncProvider(p.Name(), ps, opt))
+}
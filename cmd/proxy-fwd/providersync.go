@@ -0,0 +1,345 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SyncResult summarizes a provider sync into the pool
+type SyncResult struct {
+	Total    int          `json:"total"`
+	Added    int          `json:"added"`
+	Existing int          `json:"existing"`
+	Updated  int          `json:"updated"`  // existing entries whose address, credentials or vendor details changed
+	Restored int          `json:"restored"` // marked missing before, listed again
+	Missing  int          `json:"missing"`  // no longer listed by the vendor (marked or removed)
+	Removed  int          `json:"removed"`
+	Offline  int          `json:"offline"`  // listed but reported unusable by the vendor
+	Skipped  int          `json:"skipped"`  // offline and not added (offline=skip)
+	Conflict int          `json:"conflict"` // moved to an address another entry already uses
+	DryRun   bool         `json:"dry_run,omitempty"`
+	Changes  []SyncChange `json:"changes"`
+	Errors   []string     `json:"errors"`
+	IDs      []string     `json:"-"` // pool IDs of the listed proxies that were added or matched
+}
+
+// SyncChange is one pool entry changed (or, in a dry run, to be changed) by a sync
+type SyncChange struct {
+	ID       string   `json:"id"`
+	VendorID string   `json:"vendor_id,omitempty"`
+	Action   string   `json:"action"`           // added|updated|restored|offline|online|missing|removed|skipped|conflict
+	Fields   []string `json:"fields,omitempty"` // for updated, restored, offline and online
+}
+
+// SyncOptions controls how a sync treats the pool
+type SyncOptions struct {
+	// Missing handles pool entries of the provider whose vendor ID is not in
+	// the listing: "keep", "mark" (stop, status missing) or "remove". Only
+	// meaningful for a full account listing.
+	Missing string
+	// Offline handles proxies the vendor reports offline: "flag" (add them,
+	// stopped with status offline) or "skip" (do not add new ones)
+	Offline string
+	DryRun  bool
+}
+
+// parseSyncOptions reads ?missing=, ?offline= and ?dry_run=; order-scoped
+// syncs (?order_id=) never touch entries outside the order
+func parseSyncOptions(r *http.Request) (SyncOptions, error) {
+	q := r.URL.Query()
+	opt := SyncOptions{Missing: q.Get("missing"), Offline: q.Get("offline"), DryRun: q.Get("dry_run") == "1" || q.Get("dry_run") == "true"}
+	switch opt.Missing {
+	case "":
+		opt.Missing = "mark"
+	case "keep", "mark", "remove":
+	default:
+		return opt, invalidf("missing must be keep, mark or remove")
+	}
+	switch opt.Offline {
+	case "":
+		opt.Offline = "flag"
+	case "flag", "skip":
+	default:
+		return opt, invalidf("offline must be flag or skip")
+	}
+	if q.Get("order_id") != "" {
+		opt.Missing = "keep"
+	}
+	return opt, nil
+}
+
+// syncIntoPool adds a provider's proxies to the pool and refreshes known
+// ones, leaving entries that are not listed alone
+func (m *Manager) syncIntoPool(provider string, ps []ProviderProxy) SyncResult {
+	return m.syncProvider(provider, ps, SyncOptions{Missing: "keep", Offline: "flag"})
+}
+
+// vendorOffline reports whether a vendor status means the proxy cannot be
+// used; empty and unknown wordings count as usable
+func vendorOffline(status string) bool {
+	switch strings.ToLower(strings.TrimSpace(status)) {
+	case "offline", "inactive", "down", "dead", "error", "stopped", "paused", "suspended", "disabled", "cancelled", "canceled":
+		return true
+	}
+	return false
+}
+
+// applyVendorStatusLocked stops an entry its vendor reports offline and
+// returns it to the pool once reported usable again; expired and missing
+// entries keep their status (must be called with Manager lock held)
+func (m *Manager) applyVendorStatusLocked(it *ProxyItem) error {
+	up := it.cfg
+	offline := vendorOffline(up.VendorStatus)
+	switch {
+	case offline && up.Status != "offline" && up.Status != "expired" && up.Status != "missing":
+		err := m.stopLocked(it)
+		up.Status = "offline"
+		up.LastError = up.Provider + " reports " + up.VendorStatus
+		return err
+	case !offline && up.Status == "offline":
+		up.Status, up.LastError = "stopped", ""
+	}
+	return nil
+}
+
+// vendorSyncDiff lists the fields a vendor listing changes on cur; restart
+// reports whether a running listener must be restarted
+func vendorSyncDiff(cur, up *Upstream, p *ProviderProxy) (fields []string, restart bool) {
+	add := func(f string, changed, needsRestart bool) {
+		if changed {
+			fields = append(fields, f)
+			restart = restart || needsRestart
+		}
+	}
+	add("host", cur.Host != up.Host, true)
+	add("port", cur.Port != up.Port, true)
+	add("scheme", cur.Scheme != up.Scheme, true)
+	add("user", cur.User != up.User, true)
+	add("pass", cur.Pass != up.Pass, true)
+	add("location", up.Location != "" && cur.Location != up.Location, false)
+	add("proxy_type", p.ProxyType != "" && cur.ProxyType != up.ProxyType, false)
+	add("vendor", up.VendorID != "" && (cur.Provider != up.Provider || cur.VendorID != up.VendorID) ||
+		up.OrderID != "" && cur.OrderID != up.OrderID, false)
+	add("expires_at", up.ExpiresAt != nil && !timeEqual(cur.ExpiresAt, up.ExpiresAt), false)
+	add("socks_port", cur.SocksPort != up.SocksPort, false)
+	add("vendor_status", up.VendorStatus != "" && cur.VendorStatus != up.VendorStatus, false)
+	return fields, restart
+}
+
+// applyVendorSync copies the listed fields from up to cur
+func applyVendorSync(cur, up *Upstream, fields []string) {
+	for _, f := range fields {
+		switch f {
+		case "host":
+			cur.Host = up.Host
+		case "port":
+			cur.Port = up.Port
+		case "scheme":
+			cur.Scheme = up.Scheme
+		case "user":
+			cur.User = up.User
+		case "pass":
+			cur.Pass = up.Pass
+		case "location":
+			cur.Location = up.Location
+		case "proxy_type":
+			cur.ProxyType = up.ProxyType
+		case "vendor":
+			if up.VendorID != "" {
+				cur.Provider, cur.VendorID = up.Provider, up.VendorID
+			}
+			if up.OrderID != "" {
+				cur.OrderID = up.OrderID
+			}
+		case "expires_at":
+			cur.ExpiresAt = up.ExpiresAt
+		case "socks_port":
+			cur.SocksPort = up.SocksPort
+		case "vendor_status":
+			cur.VendorStatus = up.VendorStatus
+		}
+	}
+}
+
+// addressOwnerLocked returns the ID of an entry other than self that uses
+// up's host:port, or whose ID is the one sanitizeID gives that address; ""
+// if none (must be called with Manager lock held)
+func (m *Manager) addressOwnerLocked(up *Upstream, self *ProxyItem) string {
+	if self.cfg.Host == up.Host && self.cfg.Port == up.Port {
+		return ""
+	}
+	if it := m.items[up.ID]; it != nil && it != self {
+		return it.cfg.ID
+	}
+	for id, it := range m.items {
+		if it != self && it.cfg.Host == up.Host && it.cfg.Port == up.Port {
+			return id
+		}
+	}
+	return ""
+}
+
+// syncProvider reconciles the pool with a provider listing. Entries are
+// matched by vendor ID first, so an address change updates the entry in
+// place (keeping its ID and local port), then by host:port.
+func (m *Manager) syncProvider(provider string, ps []ProviderProxy, opt SyncOptions) SyncResult {
+	res := SyncResult{Total: len(ps), DryRun: opt.DryRun, Changes: []SyncChange{}, Errors: []string{}}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	changed := false
+	// apply runs fn unless this is a dry run, snapshotting once before the first change
+	apply := func(fn func()) {
+		if opt.DryRun {
+			return
+		}
+		if !changed {
+			changed = true
+			if _, err := m.snapshotLocked("sync", true); err != nil {
+				log.Printf("[%s Sync] Snapshot failed: %v", provider, err)
+			}
+		}
+		fn()
+	}
+
+	byVendor := make(map[string]*ProxyItem)
+	for _, it := range m.items {
+		if it.cfg.Provider == provider && it.cfg.VendorID != "" {
+			byVendor[it.cfg.VendorID] = it
+		}
+	}
+	listed := make(map[string]bool)
+	for i := range ps {
+		p := &ps[i]
+		if p.Host == "" || p.Port <= 0 || p.Port > 65535 {
+			res.Errors = append(res.Errors, fmt.Sprintf("Invalid host/port for %s:%d", p.Host, p.Port))
+			continue
+		}
+		up := p.upstream()
+		up.Provider = provider
+		offline := vendorOffline(p.Status)
+		if offline {
+			res.Offline++
+		}
+		var existing *ProxyItem
+		if p.VendorID != "" {
+			listed[p.VendorID] = true
+			existing = byVendor[p.VendorID]
+		}
+		if existing == nil {
+			existing = m.items[up.ID]
+		}
+		if existing == nil {
+			if offline && opt.Offline == "skip" {
+				res.Skipped++
+				res.Changes = append(res.Changes, SyncChange{ID: up.ID, VendorID: p.VendorID, Action: "skipped"})
+				continue
+			}
+			res.Added++
+			res.Changes = append(res.Changes, SyncChange{ID: up.ID, VendorID: p.VendorID, Action: "added"})
+			res.IDs = append(res.IDs, up.ID)
+			apply(func() {
+				it := &ProxyItem{cfg: up}
+				m.items[up.ID] = it
+				m.applyVendorStatusLocked(it)
+			})
+			continue
+		}
+		res.Existing++
+		cur := existing.cfg
+		res.IDs = append(res.IDs, cur.ID)
+		fields, restart := vendorSyncDiff(cur, up, p)
+		if other := m.addressOwnerLocked(up, existing); other != "" {
+			// moving here would leave two entries on one address
+			res.Conflict++
+			res.Changes = append(res.Changes, SyncChange{ID: cur.ID, VendorID: p.VendorID, Action: "conflict", Fields: fields})
+			res.Errors = append(res.Errors, fmt.Sprintf("%s: vendor %s moved to %s:%d, already used by %s", cur.ID, p.VendorID, up.Host, up.Port, other))
+			continue
+		}
+		restored := cur.MissingSince != nil
+		if len(fields) == 0 && !restored {
+			continue
+		}
+		action := "updated"
+		switch {
+		case restored:
+			action = "restored"
+			res.Restored++
+		case offline && !vendorOffline(cur.VendorStatus):
+			action = "offline"
+			res.Updated++
+		case !offline && vendorOffline(cur.VendorStatus):
+			action = "online"
+			res.Updated++
+		default:
+			res.Updated++
+		}
+		res.Changes = append(res.Changes, SyncChange{ID: cur.ID, VendorID: p.VendorID, Action: action, Fields: fields})
+		apply(func() {
+			applyVendorSync(cur, up, fields)
+			if restored {
+				cur.MissingSince = nil
+				if cur.Status == "missing" {
+					cur.Status, cur.LastError = "stopped", ""
+				}
+			}
+			if restart && existing.isRunning && !vendorOffline(cur.VendorStatus) {
+				if err := m.restartLocked(existing); err != nil {
+					res.Errors = append(res.Errors, fmt.Sprintf("%s: restart failed: %v", cur.ID, err))
+				}
+			}
+			if err := m.applyVendorStatusLocked(existing); err != nil {
+				res.Errors = append(res.Errors, fmt.Sprintf("%s: stop failed: %v", cur.ID, err))
+			}
+		})
+	}
+
+	if opt.Missing == "mark" || opt.Missing == "remove" {
+		if len(ps) == 0 {
+			// an empty listing is more likely a vendor glitch than a cancelled account
+			res.Errors = append(res.Errors, "empty listing, missing proxies not checked")
+		} else {
+			var gone []string
+			for vid, it := range byVendor {
+				if !listed[vid] && (opt.Missing == "remove" || it.cfg.MissingSince == nil) {
+					gone = append(gone, vid)
+				}
+			}
+			sort.Strings(gone)
+			now := time.Now()
+			for _, vid := range gone {
+				it := byVendor[vid]
+				res.Missing++
+				if opt.Missing == "remove" {
+					res.Removed++
+					res.Changes = append(res.Changes, SyncChange{ID: it.cfg.ID, VendorID: vid, Action: "removed"})
+					apply(func() { m.removeLocked(it) })
+					continue
+				}
+				res.Changes = append(res.Changes, SyncChange{ID: it.cfg.ID, VendorID: vid, Action: "missing"})
+				apply(func() {
+					if err := m.stopLocked(it); err != nil {
+						res.Errors = append(res.Errors, fmt.Sprintf("%s: stop failed: %v", it.cfg.ID, err))
+					}
+					it.cfg.MissingSince = &now
+					it.cfg.Status = "missing"
+					it.cfg.LastError = "no longer listed by " + provider
+				})
+			}
+		}
+	}
+
+	if changed {
+		_ = m.saveState()
+	}
+	prefix := ""
+	if opt.DryRun {
+		prefix = "(dry run) "
+	}
+	log.Printf("[%s Sync] %sAdded %d new proxies to pool (total: %d, updated: %d, restored: %d, offline: %d, skipped: %d, conflict: %d, missing: %d, removed: %d)",
+		provider, prefix, res.Added, res.Total, res.Updated, res.Restored, res.Offline, res.Skipped, res.Conflict, res.Missing, res.Removed)
+	return res
+}
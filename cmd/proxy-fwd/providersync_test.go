@@ -0,0 +1,55 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestSyncResultIDs checks that a sync reports the pool IDs of matched
+// entries, not IDs derived from the listed address
+func TestSyncResultIDs(t *testing.T) {
+	m := newTestManager(t)
+	m.items["renamed"] = &ProxyItem{cfg: &Upstream{ID: "renamed", Host: "1.2.3.4", Port: 8080, Provider: "test", VendorID: "v1", Status: "stopped"}}
+
+	res := m.syncIntoPool("test", []ProviderProxy{
+		{VendorID: "v1", Host: "1.2.3.5", Port: 8080, User: "u", Pass: "p"},
+		{VendorID: "v2", Host: "5.6.7.8", Port: 3128, User: "u", Pass: "p"},
+		{VendorID: "v3", Host: "", Port: 0},
+	})
+	want := []string{"renamed", sanitizeID("5.6.7.8", 3128)}
+	if !reflect.DeepEqual(res.IDs, want) {
+		t.Errorf("IDs = %q, want %q", res.IDs, want)
+	}
+	if m.items["renamed"].cfg.Host != "1.2.3.5" {
+		t.Errorf("matched entry host = %q, want the listed address", m.items["renamed"].cfg.Host)
+	}
+}
+
+// TestSyncVendorMoveConflict checks that a vendor ID moving onto an address
+// another entry already holds is reported instead of duplicating it
+func TestSyncVendorMoveConflict(t *testing.T) {
+	m := newTestManager(t)
+	m.items["a"] = &ProxyItem{cfg: &Upstream{ID: "a", Host: "1.2.3.4", Port: 8080, Provider: "test", VendorID: "v1", Status: "stopped"}}
+	m.items["b"] = &ProxyItem{cfg: &Upstream{ID: "b", Host: "5.6.7.8", Port: 3128, Status: "stopped"}}
+	taken := sanitizeID("9.9.9.9", 80)
+	m.items[taken] = &ProxyItem{cfg: &Upstream{ID: taken, Host: "9.9.9.10", Port: 80, Status: "stopped"}}
+
+	for _, addr := range []ProviderProxy{
+		{VendorID: "v1", Host: "5.6.7.8", Port: 3128},
+		{VendorID: "v1", Host: "9.9.9.9", Port: 80},
+	} {
+		res := m.syncIntoPool("test", []ProviderProxy{addr})
+		if res.Conflict != 1 || res.Updated != 0 || len(res.Errors) != 1 {
+			t.Errorf("%s:%d: conflict %d, updated %d, errors %q", addr.Host, addr.Port, res.Conflict, res.Updated, res.Errors)
+		}
+		if len(res.Changes) != 1 || res.Changes[0].Action != "conflict" || res.Changes[0].ID != "a" {
+			t.Errorf("%s:%d: changes %+v", addr.Host, addr.Port, res.Changes)
+		}
+		if cur := m.items["a"].cfg; cur.Host != "1.2.3.4" || cur.Port != 8080 {
+			t.Errorf("%s:%d: conflicting entry moved to %s:%d", addr.Host, addr.Port, cur.Host, cur.Port)
+		}
+	}
+	if len(m.items) != 3 {
+		t.Errorf("pool has %d entries, want 3", len(m.items))
+	}
+}
@@ -11,20 +11,78 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"strconv"
 	"time"
 
 	goproxy "github.com/elazarl/goproxy"
 )
 
-// startLocked starts a proxy (must be called with Manager lock held)
-func (m *Manager) startLocked(it *ProxyItem) error {
-	up := it.cfg
-	upstreamURL := &url.URL{
+// upstreamProxyURL builds the proxy URL (http:// or socks5://) of an upstream
+func upstreamProxyURL(up *Upstream) *url.URL {
+	u := &url.URL{
 		Scheme: "http",
-		Host:   fmt.Sprintf("%s:%d", up.Host, up.Port),
+		Host:   net.JoinHostPort(up.Host, strconv.Itoa(up.Port)),
+	}
+	if up.Scheme == "socks5" {
+		u.Scheme = "socks5"
 	}
 	if up.User != "" {
-		upstreamURL.User = url.UserPassword(up.User, up.Pass)
+		u.User = url.UserPassword(up.User, up.Pass)
+	}
+	return u
+}
+
+// dialThroughUpstream opens a tunnel to addr through the upstream proxy u,
+// using HTTP CONNECT or SOCKS5 depending on its scheme
+func dialThroughUpstream(u *url.URL, addr string) (net.Conn, error) {
+	password, _ := u.User.Password()
+	if u.Scheme == "socks5" {
+		return dialSOCKS5(u.Host, u.User.Username(), password, addr)
+	}
+
+	// Connect to upstream proxy first
+	proxyConn, err := net.DialTimeout("tcp", u.Host, 10*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("dial upstream proxy: %w", err)
+	}
+
+	// Send CONNECT request to upstream proxy
+	connectReq := fmt.Sprintf("CONNECT %s HTTP/1.1\r\nHost: %s\r\n", addr, addr)
+	if u.User != nil {
+		auth := u.User.Username() + ":" + password
+		encoded := "Basic " + base64.StdEncoding.EncodeToString([]byte(auth))
+		connectReq += "Proxy-Authorization: " + encoded + "\r\n"
+	}
+	connectReq += "\r\n"
+
+	if _, err := proxyConn.Write([]byte(connectReq)); err != nil {
+		proxyConn.Close()
+		return nil, fmt.Errorf("write CONNECT: %w", err)
+	}
+
+	// Read response from upstream proxy
+	br := bufio.NewReader(proxyConn)
+	resp, err := http.ReadResponse(br, &http.Request{Method: "CONNECT"})
+	if err != nil {
+		proxyConn.Close()
+		return nil, fmt.Errorf("read CONNECT response: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		proxyConn.Close()
+		return nil, fmt.Errorf("upstream proxy returned %d", resp.StatusCode)
+	}
+
+	return proxyConn, nil
+}
+
+// startLocked starts a proxy (must be called with Manager lock held, callers save state)
+func (m *Manager) startLocked(it *ProxyItem) error {
+	up := it.cfg
+	upstreamURL := upstreamProxyURL(up)
+	if m.requireLocalAuth {
+		generateLocalAuthLocked(up)
 	}
 
 	tr := &http.Transport{
@@ -44,53 +102,19 @@ func (m *Manager) startLocked(it *ProxyItem) error {
 
 	px := goproxy.NewProxyHttpServer()
 	px.Verbose = false
-	px.Tr = tr
 	// Suppress goproxy's verbose logging
 	px.Logger = log.New(io.Discard, "", 0)
 
-	// Force all CONNECT (HTTPS) requests through upstream proxy
-	px.ConnectDial = func(network, addr string) (net.Conn, error) {
-		// Connect to upstream proxy first
-		proxyConn, err := net.DialTimeout("tcp", upstreamURL.Host, 10*time.Second)
-		if err != nil {
-			return nil, fmt.Errorf("dial upstream proxy: %w", err)
-		}
-
-		// Send CONNECT request to upstream proxy
-		connectReq := fmt.Sprintf("CONNECT %s HTTP/1.1\r\nHost: %s\r\n", addr, addr)
-		if upstreamURL.User != nil {
-			username := upstreamURL.User.Username()
-			password, _ := upstreamURL.User.Password()
-			auth := username + ":" + password
-			encoded := "Basic " + base64.StdEncoding.EncodeToString([]byte(auth))
-			connectReq += "Proxy-Authorization: " + encoded + "\r\n"
-		}
-		connectReq += "\r\n"
-
-		if _, err := proxyConn.Write([]byte(connectReq)); err != nil {
-			proxyConn.Close()
-			return nil, fmt.Errorf("write CONNECT: %w", err)
-		}
-
-		// Read response from upstream proxy
-		br := bufio.NewReader(proxyConn)
-		resp, err := http.ReadResponse(br, &http.Request{Method: "CONNECT"})
-		if err != nil {
-			proxyConn.Close()
-			return nil, fmt.Errorf("read CONNECT response: %w", err)
-		}
-		resp.Body.Close()
-
-		if resp.StatusCode != 200 {
-			proxyConn.Close()
-			return nil, fmt.Errorf("upstream proxy returned %d", resp.StatusCode)
-		}
-
-		return proxyConn, nil
-	}
+	// Routing rules and destination ACLs; CONNECT (HTTPS) requests are
+	// dialed through the upstream proxy unless a rule says otherwise.
+	// Health checks always go through the proxy's own upstream.
+	healthTr := tr
+	tr = tr.Clone()
+	m.compileRoutesLocked(up).install(px, tr, upstreamURL)
+	px.Tr = tr
 
 	srv := &http.Server{
-		Addr:    fmt.Sprintf("127.0.0.1:%d", up.LocalPort),
+		Addr:    m.listenAddr(up.LocalPort),
 		Handler: px,
 		// harden timeouts
 		ReadTimeout:       30 * time.Second,
@@ -102,9 +126,11 @@ func (m *Manager) startLocked(it *ProxyItem) error {
 	if err != nil {
 		up.Status = "dead"
 		up.LastError = "listen failed: " + err.Error()
-		_ = m.saveState()
 		return err
 	}
+	if m.lan != nil {
+		ln = &lanListener{Listener: ln, lan: m.lan, id: up.ID}
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	it.server = srv
@@ -113,7 +139,6 @@ func (m *Manager) startLocked(it *ProxyItem) error {
 	it.isRunning = true
 	up.Status = "live"
 	up.LastError = ""
-	_ = m.saveState()
 
 	go func() {
 		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
@@ -126,10 +151,11 @@ func (m *Manager) startLocked(it *ProxyItem) error {
 	go func() {
 		defer it.healthWg.Done()
 		fail := 0
-		t := time.NewTicker(healthInterval)
+		hURL, hInterval, hLimit := up.healthSettings()
+		t := time.NewTicker(hInterval)
 		defer t.Stop()
 		client := &http.Client{
-			Transport: tr,
+			Transport: healthTr,
 			Timeout:   8 * time.Second,
 		}
 		for {
@@ -137,7 +163,7 @@ func (m *Manager) startLocked(it *ProxyItem) error {
 			case <-ctx.Done():
 				return
 			case <-t.C:
-				req, _ := http.NewRequest("GET", healthURL, nil)
+				req, _ := http.NewRequestWithContext(ctx, "GET", hURL, nil)
 				// avoid cache
 				req.Header.Set("Cache-Control", "no-cache")
 				resp, err := client.Do(req)
@@ -151,9 +177,11 @@ func (m *Manager) startLocked(it *ProxyItem) error {
 					continue
 				}
 				fail++
-				if fail >= healthFailLimit {
+				if fail >= hLimit {
+					if !m.lockUnlessDone(ctx) {
+						return // stopped or restarted meanwhile
+					}
 					log.Printf("[proxy %s] upstream unhealthy (%d fails), shutting down local listener", up.ID, fail)
-					m.mu.Lock()
 					_ = m.stopLocked(it)
 					up.Status = "dead"
 					up.LastError = "upstream unhealthy (auto stop)"
@@ -165,10 +193,54 @@ func (m *Manager) startLocked(it *ProxyItem) error {
 		}
 	}()
 
-	log.Printf("[proxy %s] started at http://127.0.0.1:%d -> upstream %s", up.ID, up.LocalPort, upstreamURL.Redacted())
+	log.Printf("[proxy %s] started at http://%s -> upstream %s", up.ID, srv.Addr, upstreamURL.Redacted())
 	return nil
 }
 
+// healthSettings returns the health check URL, interval and fail limit,
+// falling back to the global defaults
+func (up *Upstream) healthSettings() (string, time.Duration, int) {
+	u, iv, limit := healthURL, healthInterval, healthFailLimit
+	if up.HealthURL != "" {
+		u = up.HealthURL
+	}
+	if up.HealthInterval > 0 {
+		iv = time.Duration(up.HealthInterval) * time.Second
+	}
+	if up.HealthFailLimit > 0 {
+		limit = up.HealthFailLimit
+	}
+	return u, iv, limit
+}
+
+// lockUnlessDone takes the Manager lock unless ctx is canceled first, so a
+// health watcher never blocks a lock holder waiting for it to exit
+func (m *Manager) lockUnlessDone(ctx context.Context) bool {
+	for !m.mu.TryLock() {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	if ctx.Err() != nil {
+		m.mu.Unlock()
+		return false
+	}
+	return true
+}
+
+// restartLocked restarts a running listener on the same local port so that
+// configuration changes take effect (must be called with Manager lock held).
+// The old health watcher is waited for, so it cannot stop the new listener.
+func (m *Manager) restartLocked(it *ProxyItem) error {
+	port := it.cfg.LocalPort
+	_ = m.stopLocked(it)
+	it.healthWg.Wait()
+	it.cfg.LocalPort = port
+	return m.startLocked(it)
+}
+
 // stopLocked stops a proxy (must be called with Manager lock held)
 // Releases the local port so proxy moves to pool
 func (m *Manager) stopLocked(it *ProxyItem) error {
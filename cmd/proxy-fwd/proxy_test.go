@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestEditHealthCheckedProxy edits a running proxy while its health check is
+// in flight and checks that the old watcher does not stop the new listener
+func TestEditHealthCheckedProxy(t *testing.T) {
+	hit, release := make(chan struct{}), make(chan struct{})
+	var checks atomic.Int32
+	// the upstream gets the health checks as a proxy: the first one hangs
+	// until released and fails, later ones pass
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if checks.Add(1) > 1 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		close(hit)
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer upstream.Close()
+	host, portStr, _ := net.SplitHostPort(upstream.Listener.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	localPort := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	m := newTestManager(t)
+	m.items["p1"] = &ProxyItem{cfg: &Upstream{ID: "p1", Host: host, Port: port, LocalPort: localPort, Status: "stopped",
+		HealthURL: "http://health.example/", HealthInterval: 1, HealthFailLimit: 1}}
+	if err := m.start("p1"); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		m.mu.Lock()
+		m.stopLocked(m.items["p1"])
+		m.mu.Unlock()
+	}()
+	select {
+	case <-hit:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no health check")
+	}
+
+	user := "edited"
+	if _, err := m.update("p1", &upstreamPatch{User: &user}); err != nil {
+		t.Fatal(err)
+	}
+	// let the old check fail; it must not take the new listener down
+	close(release)
+	time.Sleep(200 * time.Millisecond)
+	m.mu.RLock()
+	running, status := m.items["p1"].isRunning, m.items["p1"].cfg.Status
+	m.mu.RUnlock()
+	if !running || status != "live" {
+		t.Errorf("after edit: running %v, status %q, want the new listener live", running, status)
+	}
+}
@@ -0,0 +1,586 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RemoteList is a vendor that publishes its proxies as a text or JSON list
+// at a URL. It is registered as a provider under its name and synced into
+// the pool every Interval seconds. Header values and URL credentials (query,
+// user info) are kept in the secrets store under SecretRef; state and API
+// responses only carry redacted copies.
+type RemoteList struct {
+	Name      string            `yaml:"name" json:"name"`
+	URL       string            `yaml:"url" json:"url"`
+	Headers   map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"` // e.g. Authorization
+	Format    string            `yaml:"format,omitempty" json:"format,omitempty"`   // lines|json, "" = by content
+	Items     string            `yaml:"items,omitempty" json:"items,omitempty"`     // JSON path of the array, "" = root
+	Fields    map[string]string `yaml:"fields,omitempty" json:"fields,omitempty"`   // host|port|user|pass|scheme|location|type|id -> JSON path in an item
+	Interval  int               `yaml:"interval,omitempty" json:"interval"`         // seconds between syncs, 0 = manual
+	SecretRef string            `yaml:"secret_ref,omitempty" json:"secret_ref,omitempty"`
+}
+
+// remoteListSecret is what a remote list keeps in the secrets store
+type remoteListSecret struct {
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// redactedValue replaces a header value kept in the secrets store; sending
+// it back in a PUT keeps the stored value
+const redactedValue = "<secret>"
+
+// remoteListSecretName is the secrets store entry of a remote list; the dot
+// keeps it apart from provider tokens, whose names cannot contain one
+func remoteListSecretName(name string) string { return "remote-list." + name }
+
+// SyncStatus is the outcome of the last sync of a remote list or a
+// scheduled provider sync
+type SyncStatus struct {
+	LastSync  time.Time   `json:"last_sync,omitempty"`
+	LastError string      `json:"last_error,omitempty"`
+	Result    *SyncResult `json:"result,omitempty"`
+}
+
+// remoteListMinInterval keeps periodic syncs from hammering a vendor
+const remoteListMinInterval = 60
+
+// defaultRemoteFields are tried when Fields does not name a path
+var defaultRemoteFields = map[string][]string{
+	"host":     {"host", "ip", "server", "address"},
+	"port":     {"port"},
+	"user":     {"user", "username", "login"},
+	"pass":     {"pass", "password"},
+	"scheme":   {"scheme", "protocol"},
+	"location": {"location", "country", "region"},
+	"id":       {"id", "pk"},
+	"type":     {"proxy_type"},
+}
+
+// remoteListProvider implements Provider for a RemoteList; only listing is
+// supported. cfg carries the real URL and headers.
+type remoteListProvider struct {
+	cfg    RemoteList
+	client *http.Client
+	err    error // set when the list's credentials cannot be read
+}
+
+func (p *remoteListProvider) Name() string { return p.cfg.Name }
+
+func (p *remoteListProvider) Regions(ctx context.Context, token, proxyType string) ([]ProviderRegion, error) {
+	return nil, errUnsupported
+}
+
+func (p *remoteListProvider) Order(ctx context.Context, token string, req OrderRequest) (string, error) {
+	return "", errUnsupported
+}
+
+func (p *remoteListProvider) OrderStatus(ctx context.Context, token, orderID string) (OrderStatus, error) {
+	return OrderStatus{}, errUnsupported
+}
+
+func (p *remoteListProvider) Renew(ctx context.Context, token, vendorID string, days int) error {
+	return errUnsupported
+}
+
+func (p *remoteListProvider) Delete(ctx context.Context, token, vendorID string) error {
+	return errUnsupported
+}
+
+// Proxies fetches and parses the list; a token, when given, is sent as a
+// Bearer Authorization header unless the list configures its own
+func (p *remoteListProvider) Proxies(ctx context.Context, token, orderID string) ([]ProviderProxy, error) {
+	if orderID != "" {
+		return nil, errUnsupported
+	}
+	ps, _, err := p.fetch(ctx, token)
+	return ps, err
+}
+
+// fetch downloads and parses the list; skipped lines and items are
+// returned as messages
+func (p *remoteListProvider) fetch(ctx context.Context, token string) ([]ProviderProxy, []string, error) {
+	if p.err != nil {
+		return nil, nil, p.err
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", p.cfg.URL, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for k, v := range p.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", p.cfg.Name, err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxImportSize))
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", p.cfg.Name, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("%s: %s returned %d", p.cfg.Name, redactURL(p.cfg.URL), resp.StatusCode)
+	}
+	return parseRemoteList(&p.cfg, b)
+}
+
+// redactURL hides credentials and query values (tokens) of a URL for messages
+func redactURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil {
+		return "<invalid url>"
+	}
+	if u.RawQuery != "" {
+		u.RawQuery = "..."
+	}
+	return u.Redacted()
+}
+
+// parseRemoteList turns a fetched body into proxies using the list's format
+// and field mapping; lines and string items that do not parse are skipped
+// and reported in skipped
+func parseRemoteList(cfg *RemoteList, b []byte) (ps []ProviderProxy, skipped []string, err error) {
+	format := cfg.Format
+	if format == "" {
+		format = "lines"
+		if t := strings.TrimSpace(string(b)); strings.HasPrefix(t, "[") || strings.HasPrefix(t, "{") {
+			format = "json"
+		}
+	}
+	if format == "lines" {
+		ups, errs := parseProxyLines(string(b))
+		if len(ups) == 0 && len(errs) > 0 {
+			return nil, nil, invalidf("%s", errs[0])
+		}
+		for _, e := range errs {
+			skipped = append(skipped, e.String())
+		}
+		res := make([]ProviderProxy, 0, len(ups))
+		for _, lp := range ups {
+			up := lp.Up
+			res = append(res, ProviderProxy{Host: up.Host, Port: up.Port, User: up.User, Pass: up.Pass, Scheme: up.Scheme})
+		}
+		return res, skipped, nil
+	}
+
+	var doc any
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, nil, invalidf("invalid JSON: %v", err)
+	}
+	v, ok := jsonPath(doc, cfg.Items)
+	if !ok {
+		return nil, nil, invalidf("items path %q not found", cfg.Items)
+	}
+	arr, ok := v.([]any)
+	if !ok {
+		return nil, nil, invalidf("items path %q is not an array", cfg.Items)
+	}
+	res := make([]ProviderProxy, 0, len(arr))
+	for i, item := range arr {
+		// arrays of proxy lines
+		if s, ok := item.(string); ok {
+			up, err := parseProxyLine(s)
+			if err != nil {
+				skipped = append(skipped, fmt.Sprintf("item %d: %v", i, err))
+				continue
+			}
+			res = append(res, ProviderProxy{Host: up.Host, Port: up.Port, User: up.User, Pass: up.Pass, Scheme: up.Scheme})
+			continue
+		}
+		p := ProviderProxy{
+			VendorID: remoteField(cfg, item, "id"),
+			Host:     remoteField(cfg, item, "host"),
+			User:     remoteField(cfg, item, "user"),
+			Pass:     remoteField(cfg, item, "pass"),
+			Location: remoteField(cfg, item, "location"),
+		}
+		p.ProxyType = remoteField(cfg, item, "type")
+		p.Port, _ = strconv.Atoi(remoteField(cfg, item, "port"))
+		if h, port, err := splitHostPort(p.Host); err == nil && p.Port == 0 {
+			p.Host, p.Port = h, port
+		}
+		switch s := strings.ToLower(remoteField(cfg, item, "scheme")); s {
+		case "socks5", "socks5h", "socks":
+			p.Scheme = "socks5"
+		}
+		res = append(res, p)
+	}
+	return res, skipped, nil
+}
+
+// remoteField reads one mapped field of a JSON item as a string
+func remoteField(cfg *RemoteList, item any, field string) string {
+	paths := defaultRemoteFields[field]
+	if p, ok := cfg.Fields[field]; ok {
+		paths = []string{p}
+	}
+	for _, path := range paths {
+		v, ok := jsonPath(item, path)
+		if !ok || v == nil {
+			continue
+		}
+		switch x := v.(type) {
+		case string:
+			return strings.TrimSpace(x)
+		case float64:
+			return strconv.FormatFloat(x, 'f', -1, 64)
+		case bool:
+			return strconv.FormatBool(x)
+		}
+	}
+	return ""
+}
+
+// jsonPath walks a decoded JSON value along a path like "$.data.items[0].host";
+// the leading "$" and "." are optional and "" is the value itself
+func jsonPath(v any, path string) (any, bool) {
+	path = strings.TrimPrefix(strings.TrimSpace(path), "$")
+	for path != "" {
+		switch path[0] {
+		case '.':
+			path = path[1:]
+		case '[':
+			end := strings.IndexByte(path, ']')
+			if end < 0 {
+				return nil, false
+			}
+			idx, err := strconv.Atoi(path[1:end])
+			arr, ok := v.([]any)
+			if err != nil || !ok || idx < 0 || idx >= len(arr) {
+				return nil, false
+			}
+			v, path = arr[idx], path[end+1:]
+		default:
+			end := strings.IndexAny(path, ".[")
+			if end < 0 {
+				end = len(path)
+			}
+			obj, ok := v.(map[string]any)
+			if !ok {
+				return nil, false
+			}
+			if v, ok = obj[path[:end]]; !ok {
+				return nil, false
+			}
+			path = path[end:]
+		}
+	}
+	return v, true
+}
+
+// validateRemoteList checks a remote list definition
+func validateRemoteList(l *RemoteList) error {
+	if !nameRe.MatchString(l.Name) {
+		return invalidf("invalid name %q (letters, digits, '-' and '_')", l.Name)
+	}
+	if p, ok := getProvider(l.Name); ok {
+		if _, remote := p.(*remoteListProvider); !remote {
+			return invalidf("name %q is a built-in provider", l.Name)
+		}
+	}
+	u, err := url.Parse(l.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return invalidf("url must be an http(s) URL")
+	}
+	switch l.Format {
+	case "", "lines", "json":
+	default:
+		return invalidf("invalid format %q (lines or json)", l.Format)
+	}
+	for f := range l.Fields {
+		if _, ok := defaultRemoteFields[f]; !ok {
+			return invalidf("unknown field %q", f)
+		}
+	}
+	if l.Interval < 0 || (l.Interval > 0 && l.Interval < remoteListMinInterval) {
+		return invalidf("interval must be 0 or at least %d seconds", remoteListMinInterval)
+	}
+	return nil
+}
+
+// remoteListsLocked returns all remote lists ordered by name
+func (m *Manager) remoteListsLocked() []*RemoteList {
+	res := make([]*RemoteList, 0, len(m.remote))
+	for _, l := range m.remote {
+		res = append(res, l)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res
+}
+
+// setRemoteListsLocked replaces all remote lists and their provider
+// registrations (used when loading state). Lists with plaintext credentials
+// are moved to the secrets store; moved reports whether any was, so the
+// caller can save the redacted state.
+func (m *Manager) setRemoteListsLocked(ls []*RemoteList) (moved bool) {
+	for name := range m.remote {
+		unregisterProvider(name)
+	}
+	m.remote = make(map[string]*RemoteList, len(ls))
+	for _, l := range ls {
+		if l == nil {
+			continue
+		}
+		if l.SecretRef != "" {
+			full, err := m.remoteListWithSecrets(l)
+			if err == nil {
+				err = validateRemoteList(full)
+			}
+			if err != nil {
+				log.Printf("[RemoteList] %s: %v", l.Name, err)
+			}
+			p := newRemoteListProvider(*full)
+			p.err = err
+			m.remote[l.Name] = l
+			registerProvider(p)
+			continue
+		}
+		if err := validateRemoteList(l); err != nil {
+			log.Printf("[RemoteList] %s ignored: %v", l.Name, err)
+			continue
+		}
+		stored, err := m.storeRemoteListLocked(l)
+		if err != nil {
+			log.Printf("[RemoteList] %s ignored: %v", l.Name, err)
+			continue
+		}
+		if stored.SecretRef != "" {
+			log.Printf("[RemoteList] %s: credentials moved to the secrets store", l.Name)
+			moved = true
+		}
+	}
+	return moved
+}
+
+func newRemoteListProvider(l RemoteList) *remoteListProvider {
+	return &remoteListProvider{cfg: l, client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// remoteListHasSecrets reports whether a list's URL or headers carry credentials
+func remoteListHasSecrets(l *RemoteList) bool {
+	if len(l.Headers) > 0 {
+		return true
+	}
+	u, err := url.Parse(l.URL)
+	return err == nil && (u.RawQuery != "" || u.User != nil)
+}
+
+// remoteListWithSecrets returns l with its URL and headers read from the
+// secrets store
+func (m *Manager) remoteListWithSecrets(l *RemoteList) (*RemoteList, error) {
+	full := *l
+	v := m.secrets.get(l.SecretRef)
+	if v == "" {
+		return &full, fmt.Errorf("credentials %q missing from the secrets store; PUT the list again", l.SecretRef)
+	}
+	var sec remoteListSecret
+	if err := json.Unmarshal([]byte(v), &sec); err != nil {
+		return &full, fmt.Errorf("credentials %q: %v", l.SecretRef, err)
+	}
+	full.URL, full.Headers = sec.URL, sec.Headers
+	return &full, nil
+}
+
+// storeRemoteListLocked registers a validated list with its real
+// credentials and keeps a redacted copy in m.remote, moving the credentials
+// into the secrets store. Without a store the list is kept as given (must be
+// called with Manager lock held)
+func (m *Manager) storeRemoteListLocked(l *RemoteList) (*RemoteList, error) {
+	stored := *l
+	stored.SecretRef = ""
+	name := remoteListSecretName(l.Name)
+	switch {
+	case m.secrets == nil:
+		// nothing to move the credentials into
+	case remoteListHasSecrets(l):
+		b, err := json.Marshal(remoteListSecret{URL: l.URL, Headers: l.Headers})
+		if err != nil {
+			return nil, err
+		}
+		if err := m.secrets.set(name, string(b)); err != nil {
+			return nil, err
+		}
+		stored.URL = redactURL(l.URL)
+		stored.Headers = make(map[string]string, len(l.Headers))
+		for k := range l.Headers {
+			stored.Headers[k] = redactedValue
+		}
+		stored.SecretRef = name
+	case m.secrets.get(name) != "":
+		if err := m.secrets.delete(name); err != nil {
+			return nil, err
+		}
+	}
+	m.remote[l.Name] = &stored
+	registerProvider(newRemoteListProvider(*l))
+	return &stored, nil
+}
+
+// putRemoteList creates or replaces a remote list. A redacted URL or header
+// value sent back unchanged keeps the stored one.
+func (m *Manager) putRemoteList(l *RemoteList) (*RemoteList, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	l.SecretRef = ""
+	if prev, ok := m.remote[l.Name]; ok && prev.SecretRef != "" {
+		if full, err := m.remoteListWithSecrets(prev); err == nil {
+			if l.URL == prev.URL {
+				l.URL = full.URL
+			}
+			for k, v := range l.Headers {
+				if v != redactedValue {
+					continue
+				}
+				if fv, ok := full.Headers[k]; ok {
+					l.Headers[k] = fv
+				}
+			}
+		}
+	}
+	for k, v := range l.Headers {
+		if v == redactedValue {
+			return nil, invalidf("header %s: no stored value to keep", k)
+		}
+	}
+	if err := validateRemoteList(l); err != nil {
+		return nil, err
+	}
+	stored, err := m.storeRemoteListLocked(l)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("[RemoteList] %s saved (%s, every %ds)", l.Name, redactURL(l.URL), l.Interval)
+	return stored, m.saveState()
+}
+
+// deleteRemoteList removes a remote list (pool entries it added are kept)
+func (m *Manager) deleteRemoteList(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.remote[name]; !ok {
+		return os.ErrNotExist
+	}
+	delete(m.remote, name)
+	delete(m.remoteStatus, name)
+	unregisterProvider(name)
+	if err := m.secrets.delete(remoteListSecretName(name)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return m.saveState()
+}
+
+// syncRemoteList fetches one list and syncs it into the pool
+func (m *Manager) syncRemoteList(ctx context.Context, name string) (*SyncResult, error) {
+	p, ok := getProvider(name)
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	rp, ok := p.(*remoteListProvider)
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	ps, skipped, err := rp.fetch(ctx, m.configuredToken(name))
+	st := &SyncStatus{LastSync: time.Now()}
+	var res SyncResult
+	if err != nil {
+		st.LastError = err.Error()
+		log.Printf("[RemoteList] %s sync failed: %v", name, err)
+	} else {
+		res = m.syncIntoPool(name, ps)
+		res.Errors = append(res.Errors, skipped...)
+		st.Result = &res
+	}
+	m.mu.Lock()
+	m.remoteStatus[name] = st
+	m.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// remoteListLoop syncs the lists that have an interval when they are due
+func (m *Manager) remoteListLoop(tick time.Duration) {
+	m.syncLoop(tick, time.Minute, func() []string {
+		return dueSyncs(m.remote, m.remoteStatus, func(l *RemoteList) int { return l.Interval })
+	}, m.syncRemoteList)
+}
+
+// remoteListView is a remote list with its sync status
+type remoteListView struct {
+	*RemoteList
+	Status *SyncStatus `json:"status,omitempty"`
+}
+
+// handleV1RemoteLists lists remote lists with their last sync status
+func (m *Manager) handleV1RemoteLists(w http.ResponseWriter, r *http.Request) {
+	m.mu.RLock()
+	items := []remoteListView{}
+	for _, l := range m.remoteListsLocked() {
+		items = append(items, remoteListView{RemoteList: l, Status: m.remoteStatus[l.Name]})
+	}
+	m.mu.RUnlock()
+	writeJSON(w, http.StatusOK, map[string]any{"items": items})
+}
+
+// handleV1RemoteListPut creates or replaces a remote list
+// Body: {"url": "https://vendor/list?key=...", "format": "json", "items": "data", "fields": {"host": "ip"}, "interval": 600}
+func (m *Manager) handleV1RemoteListPut(w http.ResponseWriter, r *http.Request) {
+	var l RemoteList
+	if !decodeJSON(w, r, &l) {
+		return
+	}
+	l.Name = r.PathValue("name")
+	res, err := m.putRemoteList(&l)
+	if err != nil {
+		writeManagerError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// handleV1RemoteListDelete removes a remote list
+func (m *Manager) handleV1RemoteListDelete(w http.ResponseWriter, r *http.Request) {
+	if err := m.deleteRemoteList(r.PathValue("name")); err != nil {
+		if os.IsNotExist(err) {
+			writeAPIError(w, http.StatusNotFound, codeNotFound, "remote list not found")
+			return
+		}
+		writeManagerError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleV1RemoteListSync syncs a remote list now
+func (m *Manager) handleV1RemoteListSync(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	m.mu.RLock()
+	_, ok := m.remote[name]
+	m.mu.RUnlock()
+	if !ok {
+		writeAPIError(w, http.StatusNotFound, codeNotFound, "remote list not found")
+		return
+	}
+	res, err := m.syncRemoteList(r.Context(), name)
+	if err != nil {
+		writeProviderError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseRemoteListLines(t *testing.T) {
+	body := "1.2.3.4:8080\nnot a proxy\nsocks5://u:p@5.6.7.8:1080\n"
+	ps, skipped, err := parseRemoteList(&RemoteList{}, []byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ps) != 2 {
+		t.Fatalf("got %d proxies, want 2", len(ps))
+	}
+	if ps[1].Host != "5.6.7.8" || ps[1].Port != 1080 || ps[1].User != "u" || ps[1].Scheme != "socks5" {
+		t.Errorf("socks line parsed as %+v", ps[1])
+	}
+	if len(skipped) != 1 || !strings.HasPrefix(skipped[0], "line 2:") {
+		t.Errorf("skipped = %q, want the error of line 2", skipped)
+	}
+}
+
+func TestParseRemoteListJSON(t *testing.T) {
+	cfg := &RemoteList{
+		Items:  "$.data.proxies",
+		Fields: map[string]string{"host": "addr", "user": "login", "pass": "pwd"},
+	}
+	body := `{"data": {"proxies": [
+		{"addr": "1.1.1.1:3128", "login": "u", "pwd": "p", "country": "VN", "pk": 7},
+		"2.2.2.2:80",
+		"nope"
+	]}}`
+	ps, skipped, err := parseRemoteList(cfg, []byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ps) != 2 {
+		t.Fatalf("got %d proxies, want 2", len(ps))
+	}
+	want := ProviderProxy{VendorID: "7", Host: "1.1.1.1", Port: 3128, User: "u", Pass: "p", Location: "VN"}
+	if ps[0] != want {
+		t.Errorf("object item parsed as %+v, want %+v", ps[0], want)
+	}
+	if ps[1].Host != "2.2.2.2" || ps[1].Port != 80 {
+		t.Errorf("string item parsed as %+v", ps[1])
+	}
+	if len(skipped) != 1 || !strings.HasPrefix(skipped[0], "item 2:") {
+		t.Errorf("skipped = %q, want the error of item 2", skipped)
+	}
+}
+
+func TestParseRemoteListBadInput(t *testing.T) {
+	tests := []struct {
+		name, items, body string
+	}{
+		{"no valid line", "", "garbage\nmore garbage\n"},
+		{"invalid JSON", "", `{"data": [`},
+		{"missing path", "$.data", `{"other": []}`},
+		{"not an array", "$.data", `{"data": {"host": "1.1.1.1"}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := parseRemoteList(&RemoteList{Items: tt.items}, []byte(tt.body)); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestSyncRemoteList(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Key") != "k" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("10.1.0.1:3128:u:p\n# comment\nbroken\n10.1.0.2:3128\n"))
+	}))
+	defer srv.Close()
+
+	m := newTestManager(t)
+	if _, err := m.putRemoteList(&RemoteList{Name: "test-lines", URL: srv.URL, Headers: map[string]string{"X-Key": "k"}}); err != nil {
+		t.Fatal(err)
+	}
+	defer m.deleteRemoteList("test-lines")
+
+	res, err := m.syncRemoteList(context.Background(), "test-lines")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Added != 2 {
+		t.Errorf("added %d, want 2", res.Added)
+	}
+	if len(res.Errors) != 1 || !strings.Contains(res.Errors[0], "line 3") {
+		t.Errorf("errors = %q, want the skipped line 3", res.Errors)
+	}
+	it, ok := m.items["10-1-0-1-3128"]
+	if !ok {
+		t.Fatal("10.1.0.1:3128 not in the pool")
+	}
+	if it.cfg.Provider != "test-lines" || it.cfg.User != "u" {
+		t.Errorf("pool entry = %+v", it.cfg)
+	}
+}
+
+func TestSyncRemoteListHTTPError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	m := newTestManager(t)
+	if _, err := m.putRemoteList(&RemoteList{Name: "test-down", URL: srv.URL + "/list?key=secret"}); err != nil {
+		t.Fatal(err)
+	}
+	defer m.deleteRemoteList("test-down")
+
+	if _, err := m.syncRemoteList(context.Background(), "test-down"); err == nil {
+		t.Fatal("expected an error")
+	}
+	st := m.remoteStatus["test-down"]
+	if st == nil || !strings.Contains(st.LastError, "returned 500") {
+		t.Fatalf("status = %+v, want the 500 recorded", st)
+	}
+	if strings.Contains(st.LastError, "secret") {
+		t.Errorf("error leaks the URL query: %s", st.LastError)
+	}
+	if len(m.items) != 0 {
+		t.Errorf("pool has %d entries after a failed sync", len(m.items))
+	}
+}
+
+func TestRemoteListCredentialsAreSealed(t *testing.T) {
+	var gotKey, gotAuth, gotQuery string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotKey, gotAuth, gotQuery = r.Header.Get("X-Key"), r.Header.Get("Authorization"), r.URL.Query().Get("key")
+		w.Write([]byte("10.2.0.1:3128\n"))
+	}))
+	defer srv.Close()
+
+	m := newTestManager(t)
+	if err := m.secrets.set("test-sealed", "provider-token"); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := m.putRemoteList(&RemoteList{Name: "test-sealed", URL: srv.URL + "/list?key=url-secret", Headers: map[string]string{"X-Key": "hdr-secret"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.deleteRemoteList("test-sealed")
+
+	if stored.Headers["X-Key"] != redactedValue || strings.Contains(stored.URL, "url-secret") || stored.SecretRef == "" {
+		t.Errorf("response not redacted: %+v", stored)
+	}
+	b, err := os.ReadFile(stateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "hdr-secret") || strings.Contains(string(b), "url-secret") {
+		t.Errorf("state file holds plaintext credentials:\n%s", b)
+	}
+
+	// a redacted copy sent back keeps the stored values
+	again := *stored
+	again.Headers = map[string]string{"X-Key": redactedValue}
+	again.Interval = 600
+	if _, err := m.putRemoteList(&again); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.syncRemoteList(context.Background(), "test-sealed"); err != nil {
+		t.Fatal(err)
+	}
+	if gotKey != "hdr-secret" || gotQuery != "url-secret" {
+		t.Errorf("vendor got X-Key %q and key %q, want the stored values", gotKey, gotQuery)
+	}
+	if gotAuth != "Bearer provider-token" {
+		t.Errorf("vendor got Authorization %q, want the configured token", gotAuth)
+	}
+
+	// deleting the list drops its credentials
+	if err := m.deleteRemoteList("test-sealed"); err != nil {
+		t.Fatal(err)
+	}
+	if m.secrets.get(remoteListSecretName("test-sealed")) != "" {
+		t.Error("credentials kept after the list was deleted")
+	}
+}
+
+func TestRemoteListPlaintextStateIsMigrated(t *testing.T) {
+	m := newTestManager(t)
+	state := "items: []\nnext: 10000\nremote_lists:\n  - name: test-legacy\n    url: https://vendor.example/list?key=old-secret\n    headers:\n      Authorization: Bearer old-header\n"
+	if err := os.WriteFile(stateFile, []byte(state), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.loadState(); err != nil {
+		t.Fatal(err)
+	}
+	defer m.deleteRemoteList("test-legacy")
+
+	b, err := os.ReadFile(stateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "old-secret") || strings.Contains(string(b), "old-header") {
+		t.Errorf("state file still holds plaintext credentials:\n%s", b)
+	}
+	p, ok := getProvider("test-legacy")
+	if !ok {
+		t.Fatal("list not registered")
+	}
+	cfg := p.(*remoteListProvider).cfg
+	if cfg.Headers["Authorization"] != "Bearer old-header" || !strings.Contains(cfg.URL, "old-secret") {
+		t.Errorf("provider lost the credentials: %+v", cfg)
+	}
+}
+
+func TestRemoteListPlaintextStateWithoutStore(t *testing.T) {
+	m := newTestManager(t)
+	m.secrets = nil
+	state := "items: []\nnext: 10000\nremote_lists:\n  - name: test-nostore\n    url: https://user:pw@vendor.example/list\n"
+	if err := os.WriteFile(stateFile, []byte(state), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.loadState(); err != nil {
+		t.Fatal(err)
+	}
+	defer unregisterProvider("test-nostore")
+
+	l := m.remote["test-nostore"]
+	if l == nil || l.SecretRef != "" || l.URL != "https://user:pw@vendor.example/list" {
+		t.Errorf("list without a store should be kept as given: %+v", l)
+	}
+	b, err := os.ReadFile(stateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != state {
+		t.Errorf("state file rewritten without a store:\n%s", b)
+	}
+}
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	goproxy "github.com/elazarl/goproxy"
+)
+
+// Route actions
+const (
+	routeUpstream = "upstream" // via another proxy's upstream (Via)
+	routeDirect   = "direct"   // straight from this machine
+	routeBlock    = "block"    // refuse with 403
+)
+
+// RouteRule sends matching traffic of a local port somewhere other than its
+// own upstream. All set conditions must match; rules are checked in order
+// and unmatched traffic uses the proxy's upstream.
+type RouteRule struct {
+	Hosts  []string `yaml:"hosts,omitempty" json:"hosts,omitempty"` // domain suffixes ("akamaized.net" matches subdomains)
+	Regex  string   `yaml:"regex,omitempty" json:"regex,omitempty"` // regexp on the host name
+	Ports  []int    `yaml:"ports,omitempty" json:"ports,omitempty"` // destination ports
+	Action string   `yaml:"action" json:"action"`                   // upstream|direct|block
+	Via    string   `yaml:"via,omitempty" json:"via,omitempty"`     // proxy ID whose upstream is used
+}
+
+// validateRoutes checks rule syntax (proxy IDs are checked by the manager)
+func validateRoutes(rules []RouteRule) error {
+	for i := range rules {
+		r := &rules[i]
+		if len(r.Hosts) == 0 && r.Regex == "" && len(r.Ports) == 0 {
+			return invalidf("route %d: hosts, regex or ports required", i+1)
+		}
+		if r.Regex != "" {
+			if _, err := regexp.Compile(r.Regex); err != nil {
+				return invalidf("route %d: invalid regex: %v", i+1, err)
+			}
+		}
+		for _, p := range r.Ports {
+			if p < 1 || p > 65535 {
+				return invalidf("route %d: invalid port %d", i+1, p)
+			}
+		}
+		switch r.Action {
+		case routeUpstream:
+			if r.Via == "" {
+				return invalidf("route %d: via required for action upstream", i+1)
+			}
+		case routeDirect, routeBlock:
+		default:
+			return invalidf("route %d: invalid action %q (upstream, direct or block)", i+1, r.Action)
+		}
+	}
+	return nil
+}
+
+// normalizeRoutes lowercases host suffixes and drops empty entries
+func normalizeRoutes(rules []RouteRule) []RouteRule {
+	for i := range rules {
+		var hosts []string
+		for _, h := range rules[i].Hosts {
+			if h = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(h)), "."); h != "" {
+				hosts = append(hosts, h)
+			}
+		}
+		rules[i].Hosts = hosts
+		rules[i].Action = strings.ToLower(strings.TrimSpace(rules[i].Action))
+	}
+	return rules
+}
+
+// routesEqual compares rule lists, treating nil and empty alike
+func routesEqual(a, b []RouteRule) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// compiledRoute is a rule ready for matching
+type compiledRoute struct {
+	RouteRule
+	re  *regexp.Regexp
+	via *url.URL
+}
+
+// routeTable holds the compiled rules and ACLs of one listener. Via
+// upstreams are resolved when the listener starts and the global ACL is read
+// atomically, so request handling never takes the manager lock.
+type routeTable struct {
+	id     string
+	rules  []compiledRoute
+	acl    *compiledACL                 // this port's ACL, may be nil
+	global *atomic.Pointer[compiledACL] // global ACL
+	user   string                       // listener credentials, "" = open
+	pass   string
+}
+
+// compileRoutesLocked builds the route table of an upstream (must be called
+// with Manager lock held). Rules naming an unknown proxy are skipped.
+func (m *Manager) compileRoutesLocked(up *Upstream) *routeTable {
+	t := &routeTable{id: up.ID, global: &m.aclCompiled, user: up.LocalUser, pass: up.LocalPass}
+	if acl, err := compileACL(up.ACL); err != nil {
+		log.Printf("[proxy %s] ACL ignored: %v", up.ID, err)
+	} else {
+		t.acl = acl
+	}
+	for i, r := range up.Routes {
+		c := compiledRoute{RouteRule: r}
+		if r.Regex != "" {
+			re, err := regexp.Compile(r.Regex)
+			if err != nil {
+				log.Printf("[proxy %s] route %d skipped: %v", up.ID, i+1, err)
+				continue
+			}
+			c.re = re
+		}
+		if r.Action == routeUpstream {
+			via, ok := m.items[r.Via]
+			if !ok {
+				log.Printf("[proxy %s] route %d skipped: unknown proxy %q", up.ID, i+1, r.Via)
+				continue
+			}
+			c.via = upstreamProxyURL(via.cfg)
+		}
+		t.rules = append(t.rules, c)
+	}
+	return t
+}
+
+// match reports whether host:port satisfies every condition of the rule
+func (c *compiledRoute) match(host string, port int) bool {
+	if len(c.Hosts) > 0 {
+		found := false
+		for _, h := range c.Hosts {
+			if host == h || strings.HasSuffix(host, "."+h) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if c.re != nil && !c.re.MatchString(host) {
+		return false
+	}
+	if len(c.Ports) > 0 {
+		found := false
+		for _, p := range c.Ports {
+			if p == port {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// decide returns the rule for host:port, or nil for the default upstream
+func (t *routeTable) decide(host string, port int) *compiledRoute {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	for i := range t.rules {
+		if t.rules[i].match(host, port) {
+			return &t.rules[i]
+		}
+	}
+	return nil
+}
+
+// splitDest splits a request host ("example.com:443") into name and port
+func splitDest(hostport string, defaultPort int) (string, int) {
+	host, portStr, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return hostport, defaultPort
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return host, defaultPort
+	}
+	return host, port
+}
+
+// requestDest returns the destination host and port of a plain HTTP request
+func requestDest(req *http.Request) (string, int) {
+	def := 80
+	if req.URL.Scheme == "https" {
+		def = 443
+	}
+	return splitDest(req.URL.Host, def)
+}
+
+// proxyAuthRealm is sent with 407 responses
+const proxyAuthRealm = `Basic realm="proxy-fwd"`
+
+// denyResponse is the 403 (blocked) or 407 (auth required) sent for plain HTTP requests
+func denyResponse(req *http.Request, status int, reason string) *http.Response {
+	resp := goproxy.NewResponse(req, goproxy.ContentTypeText, status, fmt.Sprintf("%d %s: %s\n", status, http.StatusText(status), reason))
+	if status == http.StatusProxyAuthRequired {
+		resp.Header.Set("Proxy-Authenticate", proxyAuthRealm)
+	}
+	return resp
+}
+
+// rejectConnect answers a CONNECT with status instead of opening the tunnel
+func rejectConnect(status int, reason string) *goproxy.ConnectAction {
+	return &goproxy.ConnectAction{
+		Action: goproxy.ConnectHijack,
+		Hijack: func(req *http.Request, client net.Conn, ctx *goproxy.ProxyCtx) {
+			body := fmt.Sprintf("%d %s: %s\n", status, http.StatusText(status), reason)
+			extra := ""
+			if status == http.StatusProxyAuthRequired {
+				extra = "Proxy-Authenticate: " + proxyAuthRealm + "\r\n"
+			}
+			fmt.Fprintf(client, "HTTP/1.1 %d %s\r\n%sContent-Type: text/plain\r\nContent-Length: %d\r\nConnection: close\r\n\r\n%s",
+				status, http.StatusText(status), extra, len(body), body)
+			client.Close()
+		},
+	}
+}
+
+// install wires the table into a goproxy server: clients without valid
+// credentials get a 407 and blocked destinations a 403 in the request/CONNECT
+// handlers, the rest are sent through the matching upstream (or direct) by
+// tr.Proxy and ConnectDial.
+func (t *routeTable) install(px *goproxy.ProxyHttpServer, tr *http.Transport, upstreamURL *url.URL) {
+	px.OnRequest().DoFunc(func(req *http.Request, ctx *goproxy.ProxyCtx) (*http.Request, *http.Response) {
+		if t.user != "" && !proxyAuthorized(req, t.user, t.pass) {
+			return req, denyResponse(req, http.StatusProxyAuthRequired, "proxy authentication required")
+		}
+		host, port := requestDest(req)
+		if reason := t.blocked(host, port); reason != "" {
+			log.Printf("[proxy %s] blocked %s %s from %s: %s", t.id, req.Method, req.URL.Host, clientAddr(req), reason)
+			return req, denyResponse(req, http.StatusForbidden, reason)
+		}
+		if r := t.decide(host, port); r != nil && r.Action == routeDirect {
+			// tr.DialContext checks the resolved address
+			req = req.WithContext(context.WithValue(req.Context(), directDialKey{}, true))
+		}
+		return req, nil
+	})
+	px.OnRequest().HandleConnectFunc(func(hostport string, ctx *goproxy.ProxyCtx) (*goproxy.ConnectAction, string) {
+		if t.user != "" && !proxyAuthorized(ctx.Req, t.user, t.pass) {
+			return rejectConnect(http.StatusProxyAuthRequired, "proxy authentication required"), hostport
+		}
+		host, port := splitDest(hostport, 443)
+		if reason := t.blocked(host, port); reason != "" {
+			log.Printf("[proxy %s] blocked CONNECT %s from %s: %s", t.id, hostport, clientAddr(ctx.Req), reason)
+			return rejectConnect(http.StatusForbidden, reason), hostport
+		}
+		return nil, ""
+	})
+	direct := t.directDialer()
+	dial := tr.DialContext
+	tr.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if ctx.Value(directDialKey{}) != nil {
+			return direct.DialContext(ctx, network, addr)
+		}
+		return dial(ctx, network, addr)
+	}
+	tr.Proxy = func(req *http.Request) (*url.URL, error) {
+		r := t.decide(requestDest(req))
+		switch {
+		case r == nil:
+			return upstreamURL, nil
+		case r.Action == routeDirect:
+			return nil, nil
+		case r.Action == routeUpstream:
+			return r.via, nil
+		}
+		return nil, fmt.Errorf("blocked by route")
+	}
+	px.ConnectDial = func(network, addr string) (net.Conn, error) {
+		r := t.decide(splitDest(addr, 443))
+		switch {
+		case r == nil:
+			return dialThroughUpstream(upstreamURL, addr)
+		case r.Action == routeDirect:
+			return direct.Dial(network, addr)
+		case r.Action == routeUpstream:
+			return dialThroughUpstream(r.via, addr)
+		}
+		return nil, fmt.Errorf("blocked by route")
+	}
+}
+
+// directDialKey marks requests that a direct route sends from this machine
+type directDialKey struct{}
+
+// directDialer dials the destinations of direct routes. ACL CIDRs only match
+// literal IPs when a request is checked, so the address a host name resolves
+// to is checked against the deny CIDRs again before connecting.
+func (t *routeTable) directDialer() *net.Dialer {
+	return &net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			ap, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return nil
+			}
+			if reason := t.blockedIP(ap.Addr()); reason != "" {
+				log.Printf("[proxy %s] blocked direct dial to %s: %s", t.id, address, reason)
+				return fmt.Errorf("%s: %s", address, reason)
+			}
+			return nil
+		},
+	}
+}
+
+// blockedIP returns why a resolved address is refused by the global or this
+// port's ACL, or "" if it is allowed
+func (t *routeTable) blockedIP(ip netip.Addr) string {
+	if reason := t.global.Load().checkIP(ip); reason != "" {
+		return reason + " (global ACL)"
+	}
+	return t.acl.checkIP(ip)
+}
+
+// blocked returns why host:port is refused, or "" if it is allowed. The
+// global ACL is checked first, then this port's ACL, then block routes.
+func (t *routeTable) blocked(host string, port int) string {
+	if reason := t.global.Load().check(host, port); reason != "" {
+		return reason + " (global ACL)"
+	}
+	if reason := t.acl.check(host, port); reason != "" {
+		return reason
+	}
+	if r := t.decide(host, port); r != nil && r.Action == routeBlock {
+		return "blocked by route rule"
+	}
+	return ""
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestDirectDialChecksResolvedAddress(t *testing.T) {
+	srv := httptest.NewServer(nil)
+	defer srv.Close()
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	addr := net.JoinHostPort("localhost", port)
+
+	acl, err := compileACL(&DestACL{Deny: ACLRules{CIDRs: []string{"127.0.0.0/8", "::1"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the host name is not a literal IP, so only the dial-time check can refuse it
+	if reason := acl.check("localhost", 80); reason != "" {
+		t.Fatalf("check(localhost) = %q, want allowed", reason)
+	}
+	tbl := &routeTable{id: "t", acl: acl, global: &atomic.Pointer[compiledACL]{}}
+	if c, err := tbl.directDialer().Dial("tcp", addr); err == nil {
+		c.Close()
+		t.Fatal("dial to a denied address succeeded")
+	} else if !strings.Contains(err.Error(), "destination address denied") {
+		t.Fatalf("dial error = %v", err)
+	}
+
+	tbl = &routeTable{id: "t", global: &atomic.Pointer[compiledACL]{}}
+	tbl.global.Store(acl)
+	if c, err := tbl.directDialer().Dial("tcp", addr); err == nil {
+		c.Close()
+		t.Fatal("dial to an address denied by the global ACL succeeded")
+	}
+
+	tbl = &routeTable{id: "t", global: &atomic.Pointer[compiledACL]{}}
+	c, err := tbl.directDialer().Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("dial without ACL: %v", err)
+	}
+	c.Close()
+}
+
+func TestCheckIPUnmapsAddresses(t *testing.T) {
+	acl, err := compileACL(&DestACL{Deny: ACLRules{CIDRs: []string{"10.0.0.0/8"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acl.checkIP(netip.MustParseAddr("::ffff:10.1.2.3")) == "" {
+		t.Error("IPv4-mapped address in a denied range was allowed")
+	}
+	if acl.checkIP(netip.MustParseAddr("192.168.1.1")) != "" {
+		t.Error("address outside the denied range was refused")
+	}
+	var none *compiledACL
+	if none.checkIP(netip.MustParseAddr("10.1.2.3")) != "" {
+		t.Error("nil ACL refused an address")
+	}
+}
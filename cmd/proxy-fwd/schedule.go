@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+)
+
+// ProviderSchedule syncs a provider's whole account into the pool on an
+// interval, with the token from the server-side configuration
+type ProviderSchedule struct {
+	Provider string `yaml:"provider" json:"provider"`
+	Interval int    `yaml:"interval" json:"interval"`                   // seconds, 0 = paused
+	Missing  string `yaml:"missing,omitempty" json:"missing,omitempty"` // keep|mark|remove, default mark
+	Offline  string `yaml:"offline,omitempty" json:"offline,omitempty"` // flag|skip, default flag
+}
+
+// scheduleMinInterval keeps scheduled syncs from hammering a vendor API
+const scheduleMinInterval = 300
+
+// validateSchedule checks a schedule and fills in defaults
+func validateSchedule(s *ProviderSchedule) error {
+	p, ok := getProvider(s.Provider)
+	if !ok {
+		return invalidf("unknown provider %q", s.Provider)
+	}
+	if _, remote := p.(*remoteListProvider); remote {
+		return invalidf("%q is a remote list; set its interval instead", s.Provider)
+	}
+	if s.Interval < 0 || (s.Interval > 0 && s.Interval < scheduleMinInterval) {
+		return invalidf("interval must be 0 or at least %d seconds", scheduleMinInterval)
+	}
+	switch s.Missing {
+	case "":
+		s.Missing = "mark"
+	case "keep", "mark", "remove":
+	default:
+		return invalidf("missing must be keep, mark or remove")
+	}
+	switch s.Offline {
+	case "":
+		s.Offline = "flag"
+	case "flag", "skip":
+	default:
+		return invalidf("offline must be flag or skip")
+	}
+	return nil
+}
+
+// schedulesLocked returns all schedules ordered by provider
+func (m *Manager) schedulesLocked() []*ProviderSchedule {
+	res := make([]*ProviderSchedule, 0, len(m.schedules))
+	for _, s := range m.schedules {
+		res = append(res, s)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Provider < res[j].Provider })
+	return res
+}
+
+// setSchedulesLocked replaces all schedules (used when loading state)
+func (m *Manager) setSchedulesLocked(ss []*ProviderSchedule) {
+	m.schedules = make(map[string]*ProviderSchedule, len(ss))
+	for _, s := range ss {
+		if s == nil {
+			continue
+		}
+		if err := validateSchedule(s); err != nil {
+			log.Printf("[Schedule] %s ignored: %v", s.Provider, err)
+			continue
+		}
+		m.schedules[s.Provider] = s
+	}
+}
+
+// putSchedule creates or replaces the schedule of a provider
+func (m *Manager) putSchedule(s *ProviderSchedule) (*ProviderSchedule, error) {
+	if err := validateSchedule(s); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.schedules[s.Provider] = s
+	log.Printf("[Schedule] %s: sync every %ds (missing: %s, offline: %s)", s.Provider, s.Interval, s.Missing, s.Offline)
+	if m.configuredToken(s.Provider) == "" {
+		log.Printf("[Schedule] %s: no token configured, scheduled syncs will fail", s.Provider)
+	}
+	return s, m.saveState()
+}
+
+// deleteSchedule stops the scheduled sync of a provider
+func (m *Manager) deleteSchedule(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.schedules[name]; !ok {
+		return os.ErrNotExist
+	}
+	delete(m.schedules, name)
+	delete(m.scheduleStatus, name)
+	return m.saveState()
+}
+
+// runSchedule syncs one provider with its configured token and records the outcome
+func (m *Manager) runSchedule(ctx context.Context, name string) (*SyncResult, error) {
+	m.mu.RLock()
+	s, ok := m.schedules[name]
+	var opt SyncOptions
+	if ok {
+		opt.Missing, opt.Offline = s.Missing, s.Offline
+	}
+	m.mu.RUnlock()
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	st := &SyncStatus{LastSync: time.Now()}
+	var res SyncResult
+	p, ok := getProvider(name)
+	token := m.configuredToken(name)
+	var err error
+	switch {
+	case !ok:
+		err = fmt.Errorf("unknown provider %q", name)
+	case token == "":
+		err = invalidf("no %s token configured", name)
+	default:
+		var ps []ProviderProxy
+		if ps, err = p.Proxies(ctx, token, ""); err == nil {
+			res = m.syncProvider(name, ps, opt)
+			st.Result = &res
+		}
+	}
+	if err != nil {
+		st.LastError = err.Error()
+		log.Printf("[Schedule] %s sync failed: %v", name, err)
+	}
+	m.mu.Lock()
+	m.scheduleStatus[name] = st
+	m.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// scheduleLoop runs the schedules that are due; it shares the remote list tick
+func (m *Manager) scheduleLoop(tick time.Duration) {
+	m.syncLoop(tick, 2*time.Minute, func() []string {
+		return dueSyncs(m.schedules, m.scheduleStatus, func(s *ProviderSchedule) int { return s.Interval })
+	}, m.runSchedule)
+}
+
+// syncLoop calls sync on every tick for the names due returns (called with
+// the Manager read lock held); each sync gets its own timeout
+func (m *Manager) syncLoop(tick, timeout time.Duration, due func() []string, sync func(context.Context, string) (*SyncResult, error)) {
+	t := time.NewTicker(tick)
+	defer t.Stop()
+	for range t.C {
+		m.mu.RLock()
+		names := due()
+		m.mu.RUnlock()
+		for _, name := range names {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			sync(ctx, name)
+			cancel()
+		}
+	}
+}
+
+// dueSyncs lists the names whose interval in seconds (0 = manual) has passed
+// since their last sync, or that never synced
+func dueSyncs[T any](items map[string]T, status map[string]*SyncStatus, interval func(T) int) []string {
+	var due []string
+	for name, it := range items {
+		iv := interval(it)
+		if iv <= 0 {
+			continue
+		}
+		st := status[name]
+		if st == nil || time.Since(st.LastSync) >= time.Duration(iv)*time.Second {
+			due = append(due, name)
+		}
+	}
+	return due
+}
+
+// scheduleView is a schedule with its last outcome
+type scheduleView struct {
+	*ProviderSchedule
+	TokenConfigured bool        `json:"token_configured"`
+	NextSync        *time.Time  `json:"next_sync,omitempty"`
+	Status          *SyncStatus `json:"status,omitempty"`
+}
+
+// scheduleViewLocked builds the view of one schedule (must be called with Manager lock held)
+func (m *Manager) scheduleViewLocked(s *ProviderSchedule) scheduleView {
+	v := scheduleView{ProviderSchedule: s, TokenConfigured: m.configuredToken(s.Provider) != "", Status: m.scheduleStatus[s.Provider]}
+	if s.Interval > 0 {
+		next := time.Now()
+		if v.Status != nil {
+			next = v.Status.LastSync.Add(time.Duration(s.Interval) * time.Second)
+		}
+		v.NextSync = &next
+	}
+	return v
+}
+
+// handleV1Schedules lists scheduled provider syncs with their last outcome
+func (m *Manager) handleV1Schedules(w http.ResponseWriter, r *http.Request) {
+	m.mu.RLock()
+	items := []scheduleView{}
+	for _, s := range m.schedulesLocked() {
+		items = append(items, m.scheduleViewLocked(s))
+	}
+	m.mu.RUnlock()
+	writeJSON(w, http.StatusOK, map[string]any{"items": items})
+}
+
+// handleV1Schedule returns one scheduled provider sync
+func (m *Manager) handleV1Schedule(w http.ResponseWriter, r *http.Request) {
+	m.mu.RLock()
+	s, ok := m.schedules[r.PathValue("name")]
+	var v scheduleView
+	if ok {
+		v = m.scheduleViewLocked(s)
+	}
+	m.mu.RUnlock()
+	if !ok {
+		writeAPIError(w, http.StatusNotFound, codeNotFound, "schedule not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, v)
+}
+
+// handleV1SchedulePut creates or replaces a scheduled provider sync
+// Body: {"interval": 3600, "missing": "mark", "offline": "flag"}
+func (m *Manager) handleV1SchedulePut(w http.ResponseWriter, r *http.Request) {
+	var s ProviderSchedule
+	if !decodeJSON(w, r, &s) {
+		return
+	}
+	s.Provider = r.PathValue("name")
+	res, err := m.putSchedule(&s)
+	if err != nil {
+		writeManagerError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// handleV1ScheduleDelete removes a scheduled provider sync
+func (m *Manager) handleV1ScheduleDelete(w http.ResponseWriter, r *http.Request) {
+	if err := m.deleteSchedule(r.PathValue("name")); err != nil {
+		if os.IsNotExist(err) {
+			writeAPIError(w, http.StatusNotFound, codeNotFound, "schedule not found")
+			return
+		}
+		writeManagerError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleV1ScheduleRun runs a scheduled provider sync now
+func (m *Manager) handleV1ScheduleRun(w http.ResponseWriter, r *http.Request) {
+	res, err := m.runSchedule(r.Context(), r.PathValue("name"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			writeAPIError(w, http.StatusNotFound, codeNotFound, "schedule not found")
+			return
+		}
+		writeProviderError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestDueSyncs(t *testing.T) {
+	intervals := map[string]int{"manual": 0, "never": 300, "recent": 300, "stale": 300}
+	status := map[string]*SyncStatus{
+		"recent": {LastSync: time.Now().Add(-time.Minute)},
+		"stale":  {LastSync: time.Now().Add(-10 * time.Minute)},
+	}
+	got := dueSyncs(intervals, status, func(iv int) int { return iv })
+	sort.Strings(got)
+	if want := []string{"never", "stale"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("due = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	secretsFileName = "secrets.yaml"
+	secretsKeyName  = "secrets.key"
+)
+
+// secretStore keeps provider credentials encrypted at rest with AES-256-GCM.
+// The key lives in its own file (SECRETS_KEY_FILE, default secrets.key next
+// to proxies.yaml) and is created on first start; values are never returned
+// by the API.
+type secretStore struct {
+	mu    sync.RWMutex
+	path  string
+	aead  cipher.AEAD
+	items map[string]secretEntry
+}
+
+type secretEntry struct {
+	Value   string    `yaml:"value"` // base64(nonce | ciphertext) on disk, plaintext in memory
+	Updated time.Time `yaml:"updated"`
+}
+
+// openSecrets loads the store, creating the key file if it does not exist
+func openSecrets(path, keyFile string) (*secretStore, error) {
+	key, err := loadSecretsKey(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	s := &secretStore{path: path, aead: aead, items: make(map[string]secretEntry)}
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var onDisk map[string]secretEntry
+	if err := yaml.Unmarshal(b, &onDisk); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	for name, e := range onDisk {
+		v, err := s.open(name, e.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: cannot decrypt %q (wrong key file?): %v", path, name, err)
+		}
+		s.items[name] = secretEntry{Value: v, Updated: e.Updated}
+	}
+	return s, nil
+}
+
+// loadSecretsKey reads a hex-encoded 32-byte key, generating one if missing
+func loadSecretsKey(keyFile string) ([]byte, error) {
+	b, err := os.ReadFile(keyFile)
+	if os.IsNotExist(err) {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(keyFile, []byte(hex.EncodeToString(key)+"\n"), 0600); err != nil {
+			return nil, err
+		}
+		log.Printf("[Secrets] created key file %s", keyFile)
+		return key, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(string(b)))
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("%s: expected 64 hex characters", keyFile)
+	}
+	return key, nil
+}
+
+// seal encrypts a value; the name is authenticated so values cannot be swapped
+func (s *secretStore) seal(name, value string) (string, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	ct := s.aead.Seal(nonce, nonce, []byte(value), []byte(name))
+	return base64.StdEncoding.EncodeToString(ct), nil
+}
+
+func (s *secretStore) open(name, sealed string) (string, error) {
+	b, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return "", err
+	}
+	n := s.aead.NonceSize()
+	if len(b) < n {
+		return "", fmt.Errorf("truncated value")
+	}
+	pt, err := s.aead.Open(nil, b[:n], b[n:], []byte(name))
+	if err != nil {
+		return "", err
+	}
+	return string(pt), nil
+}
+
+// saveLocked writes the encrypted store (must be called with store lock held)
+func (s *secretStore) saveLocked() error {
+	onDisk := make(map[string]secretEntry, len(s.items))
+	for name, e := range s.items {
+		v, err := s.seal(name, e.Value)
+		if err != nil {
+			return err
+		}
+		onDisk[name] = secretEntry{Value: v, Updated: e.Updated}
+	}
+	b, err := yaml.Marshal(onDisk)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, b, 0600)
+}
+
+// get returns a secret, "" when unset
+func (s *secretStore) get(name string) string {
+	if s == nil {
+		return ""
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.items[name].Value
+}
+
+func (s *secretStore) set(name, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items[name] = secretEntry{Value: value, Updated: time.Now()}
+	return s.saveLocked()
+}
+
+func (s *secretStore) delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.items[name]; !ok {
+		return os.ErrNotExist
+	}
+	delete(s.items, name)
+	return s.saveLocked()
+}
+
+// secretInfo describes a stored secret without its value
+type secretInfo struct {
+	Name    string    `json:"name"`
+	Updated time.Time `json:"updated"`
+}
+
+func (s *secretStore) list() []secretInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	res := make([]secretInfo, 0, len(s.items))
+	for name, e := range s.items {
+		res = append(res, secretInfo{Name: name, Updated: e.Updated})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res
+}
+
+// secretsPaths returns the store and key file locations
+func secretsPaths() (path, keyFile string) {
+	dir := filepath.Dir(stateFile)
+	return filepath.Join(dir, secretsFileName), getenv("SECRETS_KEY_FILE", filepath.Join(dir, secretsKeyName))
+}
+
+// handleV1Secrets lists stored secrets by name (values are never returned)
+func (m *Manager) handleV1Secrets(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{"items": m.secrets.list()})
+}
+
+// handleV1SecretPut stores a secret; a provider's token is the secret named
+// after the provider
+// Body: {"value": "..."}
+func (m *Manager) handleV1SecretPut(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if !nameRe.MatchString(name) {
+		writeAPIError(w, http.StatusBadRequest, codeBadRequest, "invalid name (letters, digits, '-' and '_')")
+		return
+	}
+	var body struct {
+		Value string `json:"value"`
+	}
+	if !decodeJSON(w, r, &body) {
+		return
+	}
+	if body.Value = strings.TrimSpace(body.Value); body.Value == "" {
+		writeAPIError(w, http.StatusBadRequest, codeBadRequest, "value required")
+		return
+	}
+	if err := m.secrets.set(name, body.Value); err != nil {
+		writeManagerError(w, err)
+		return
+	}
+	log.Printf("[Secrets] %s stored", name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleV1SecretDelete removes a secret
+func (m *Manager) handleV1SecretDelete(w http.ResponseWriter, r *http.Request) {
+	if err := m.secrets.delete(r.PathValue("name")); err != nil {
+		if os.IsNotExist(err) {
+			writeAPIError(w, http.StatusNotFound, codeNotFound, "secret not found")
+			return
+		}
+		writeManagerError(w, err)
+		return
+	}
+	log.Printf("[Secrets] %s deleted", r.PathValue("name"))
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// dialSOCKS5 connects to addr through a SOCKS5 proxy (RFC 1928), using
+// username/password authentication (RFC 1929) when user is set. The
+// destination host is sent as a domain name so the proxy resolves it.
+func dialSOCKS5(proxyAddr, user, pass, addr string) (net.Conn, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 1 || port > 65535 {
+		return nil, fmt.Errorf("invalid port in %q", addr)
+	}
+	if len(host) > 255 || len(user) > 255 || len(pass) > 255 {
+		return nil, errors.New("socks5: host or credentials too long")
+	}
+
+	conn, err := net.DialTimeout("tcp", proxyAddr, 10*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("dial upstream proxy: %w", err)
+	}
+	conn.SetDeadline(time.Now().Add(15 * time.Second))
+	if err := socks5Handshake(conn, user, pass, host, port); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+// socks5Handshake negotiates auth and sends a CONNECT request on conn
+func socks5Handshake(conn net.Conn, user, pass, host string, port int) error {
+	method := byte(0x00) // no auth
+	if user != "" {
+		method = 0x02 // username/password
+	}
+	if _, err := conn.Write([]byte{0x05, 0x01, method}); err != nil {
+		return fmt.Errorf("socks5 greeting: %w", err)
+	}
+	var resp [2]byte
+	if _, err := io.ReadFull(conn, resp[:]); err != nil {
+		return fmt.Errorf("socks5 greeting: %w", err)
+	}
+	if resp[0] != 0x05 || resp[1] != method {
+		return fmt.Errorf("socks5: method %d not accepted", method)
+	}
+
+	if method == 0x02 {
+		req := []byte{0x01, byte(len(user))}
+		req = append(req, user...)
+		req = append(req, byte(len(pass)))
+		req = append(req, pass...)
+		if _, err := conn.Write(req); err != nil {
+			return fmt.Errorf("socks5 auth: %w", err)
+		}
+		if _, err := io.ReadFull(conn, resp[:]); err != nil {
+			return fmt.Errorf("socks5 auth: %w", err)
+		}
+		if resp[1] != 0x00 {
+			return errors.New("socks5: authentication failed")
+		}
+	}
+
+	req := []byte{0x05, 0x01, 0x00}
+	if ip := net.ParseIP(host); ip != nil && ip.To4() != nil {
+		req = append(req, 0x01)
+		req = append(req, ip.To4()...)
+	} else if ip != nil {
+		req = append(req, 0x04)
+		req = append(req, ip.To16()...)
+	} else {
+		req = append(req, 0x03, byte(len(host)))
+		req = append(req, host...)
+	}
+	req = binary.BigEndian.AppendUint16(req, uint16(port))
+	if _, err := conn.Write(req); err != nil {
+		return fmt.Errorf("socks5 connect: %w", err)
+	}
+
+	var hdr [4]byte
+	if _, err := io.ReadFull(conn, hdr[:]); err != nil {
+		return fmt.Errorf("socks5 connect: %w", err)
+	}
+	if hdr[1] != 0x00 {
+		return fmt.Errorf("socks5: connect failed with code %d", hdr[1])
+	}
+	// skip bound address and port
+	var skip int
+	switch hdr[3] {
+	case 0x01:
+		skip = net.IPv4len + 2
+	case 0x04:
+		skip = net.IPv6len + 2
+	case 0x03:
+		var l [1]byte
+		if _, err := io.ReadFull(conn, l[:]); err != nil {
+			return fmt.Errorf("socks5 connect: %w", err)
+		}
+		skip = int(l[0]) + 2
+	default:
+		return fmt.Errorf("socks5: bad address type %d", hdr[3])
+	}
+	if _, err := io.CopyN(io.Discard, conn, int64(skip)); err != nil {
+		return fmt.Errorf("socks5 connect: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+)
+
+// normalizeTags trims, lowercases and de-duplicates tags
+func normalizeTags(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	res := make([]string, 0, len(tags))
+	for _, t := range tags {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+		res = append(res, t)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// parseTagSelector parses a comma separated tag selector ("client-a,profile-3")
+func parseTagSelector(s string) []string {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+	return normalizeTags(strings.Split(s, ","))
+}
+
+// hasTags reports whether up carries every tag in sel (an empty selector matches all)
+func hasTags(up *Upstream, sel []string) bool {
+	for _, want := range sel {
+		found := false
+		for _, t := range up.Tags {
+			if t == want {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// setLabels updates tags and/or notes of a proxy; nil leaves a field unchanged
+func (m *Manager) setLabels(id string, tags []string, notes *string) (*Upstream, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	it, ok := m.items[id]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	if tags != nil {
+		it.cfg.Tags = normalizeTags(tags)
+	}
+	if notes != nil {
+		it.cfg.Notes = strings.TrimSpace(*notes)
+	}
+	if err := m.saveState(); err != nil {
+		// os.IsNotExist does not unwrap, so the handler still tells this from an unknown proxy
+		return nil, fmt.Errorf("save state: %w", err)
+	}
+	return it.cfg, nil
+}
+
+// handleSetLabels sets tags and notes on a proxy
+// Body: {"tags": ["client-a"], "notes": "..."}; omitted fields are kept
+func (m *Manager) handleSetLabels(w http.ResponseWriter, r *http.Request) {
+	if !m.handleAuth(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "missing id", 400)
+		return
+	}
+	var body struct {
+		Tags  []string `json:"tags"`
+		Notes *string  `json:"notes"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&body); err != nil {
+		http.Error(w, "Invalid JSON: "+err.Error(), 400)
+		return
+	}
+	up, err := m.setLabels(id, body.Tags, body.Notes)
+	if err != nil {
+		if os.IsNotExist(err) {
+			http.Error(w, "proxy not found", 404)
+			return
+		}
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	json.NewEncoder(w).Encode(up)
+}
+
+// handleTagAction applies a bulk operation to every proxy matching ?tag=
+// (all listed tags required)
+func (m *Manager) handleTagAction(op func(*bulkTarget) ([]BulkResult, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !m.handleAuth(r) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		tag := r.URL.Query().Get("tag")
+		if len(parseTagSelector(tag)) == 0 {
+			http.Error(w, "missing tag", 400)
+			return
+		}
+		res, err := op(&bulkTarget{Selector: &bulkSelector{Tag: tag}})
+		if err != nil {
+			writeLegacyError(w, err)
+			return
+		}
+		writeBulkResults(w, res)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSetLabelsErrors(t *testing.T) {
+	m := newTestManager(t)
+	m.items["p1"] = &ProxyItem{cfg: &Upstream{ID: "p1", Host: "1.2.3.4", Port: 8080, Status: "stopped"}}
+	set := func(id string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		m.handleSetLabels(w, httptest.NewRequest(http.MethodPost, "/api/tags?id="+id, strings.NewReader(`{"tags": ["a"]}`)))
+		return w
+	}
+
+	if w := set("p1"); w.Code != http.StatusOK {
+		t.Errorf("known proxy: %d %s", w.Code, w.Body)
+	}
+	if w := set("nope"); w.Code != http.StatusNotFound {
+		t.Errorf("unknown proxy: %d, want 404", w.Code)
+	}
+	// a state file in a missing directory fails to save with ENOENT
+	stateFile = filepath.Join(t.TempDir(), "gone", "proxies.yaml")
+	if w := set("p1"); w.Code != http.StatusInternalServerError {
+		t.Errorf("save failure: %d %s, want 500", w.Code, w.Body)
+	}
+}
+
+func TestTagActionRequiresPost(t *testing.T) {
+	m := newTestManager(t)
+	m.items["p1"] = &ProxyItem{cfg: &Upstream{ID: "p1", Host: "1.2.3.4", Port: 8080, Tags: []string{"a"}, Status: "stopped"}}
+	w := httptest.NewRecorder()
+	m.handleTagAction(m.bulkRemove)(w, httptest.NewRequest(http.MethodGet, "/api/tag/remove?tag=a", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET: %d, want 405", w.Code)
+	}
+	if _, ok := m.items["p1"]; !ok {
+		t.Error("GET removed the proxy")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"net"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -14,6 +15,13 @@ const (
 	healthURL       = "http://www.gstatic.com/generate_204" // lightweight 204
 	healthInterval  = 10 * time.Second
 	healthFailLimit = 3
+
+	stateWatchInterval = 2 * time.Second
+	remoteListTick     = 30 * time.Second
+
+	expiryTick     = time.Minute
+	expiryWarnDays = 3
+	renewRetry     = 6 * time.Hour // between auto-renew attempts for one proxy
 )
 
 // stateFile will be set to executable_dir/proxies.yaml in init()
@@ -21,23 +29,54 @@ var stateFile string
 
 // Upstream represents a single upstream proxy configuration
 type Upstream struct {
-	ID        string `yaml:"id" json:"id"`
-	Host      string `yaml:"host" json:"host"`
-	Port      int    `yaml:"port" json:"port"`
-	User      string `yaml:"user" json:"user"`
-	Pass      string `yaml:"pass" json:"pass"`
-	LocalPort int    `yaml:"local_port" json:"local_port"`
-	ProxyType string `yaml:"proxy_type" json:"proxy_type"` // residential|privatev4|datacenter|static|unknown
-	Location  string `yaml:"location" json:"location"`     // Geographic location
-
-	Status    string `yaml:"status" json:"status"` // creating|live|dead|stopped
+	ID        string   `yaml:"id" json:"id"`
+	Host      string   `yaml:"host" json:"host"`
+	Port      int      `yaml:"port" json:"port"`
+	User      string   `yaml:"user" json:"user"`
+	Pass      string   `yaml:"pass" json:"pass"`
+	Scheme    string   `yaml:"scheme,omitempty" json:"scheme,omitempty"` // upstream protocol: "" (http) or socks5
+	LocalPort int      `yaml:"local_port" json:"local_port"`
+	ProxyType string   `yaml:"proxy_type" json:"proxy_type"` // residential|privatev4|datacenter|static|unknown
+	Location  string   `yaml:"location" json:"location"`     // Geographic location
+	Tags      []string `yaml:"tags,omitempty" json:"tags"`   // free-form labels (client, campaign, profile...)
+	Notes     string   `yaml:"notes,omitempty" json:"notes"` // free-form notes
+
+	// Health check overrides; zero values use healthURL/healthInterval/healthFailLimit
+	HealthURL       string `yaml:"health_url,omitempty" json:"health_url,omitempty"`
+	HealthInterval  int    `yaml:"health_interval,omitempty" json:"health_interval,omitempty"` // seconds
+	HealthFailLimit int    `yaml:"health_fail_limit,omitempty" json:"health_fail_limit,omitempty"`
+
+	// Per-port routing rules (see route.go)
+	Routes []RouteRule `yaml:"routes,omitempty" json:"routes,omitempty"`
+	// Basic Proxy-Authorization required by the local listener ("" = none)
+	LocalUser string `yaml:"local_user,omitempty" json:"local_user,omitempty"`
+	LocalPass string `yaml:"local_pass,omitempty" json:"local_pass,omitempty"`
+	// Destination ACL of this port, checked after the global one (see acl.go)
+	ACL *DestACL `yaml:"acl,omitempty" json:"acl,omitempty"`
+	// Vendor bookkeeping, filled by provider syncs (see expiry.go)
+	Provider  string     `yaml:"provider,omitempty" json:"provider,omitempty"`
+	VendorID  string     `yaml:"vendor_id,omitempty" json:"vendor_id,omitempty"` // vendor primary key
+	OrderID   string     `yaml:"order_id,omitempty" json:"order_id,omitempty"`
+	ExpiresAt *time.Time `yaml:"expires_at,omitempty" json:"expires_at,omitempty"`
+	// SOCKS5 port of the same vendor proxy, an alternate endpoint (0 = none)
+	SocksPort int `yaml:"socks_port,omitempty" json:"socks_port,omitempty"`
+	// vendor's own status wording (e.g. online); see vendorOffline
+	VendorStatus string `yaml:"vendor_status,omitempty" json:"vendor_status,omitempty"`
+	// set when a full sync no longer lists the vendor ID (see providersync.go)
+	MissingSince *time.Time `yaml:"missing_since,omitempty" json:"missing_since,omitempty"`
+
+	Status    string `yaml:"status" json:"status"` // creating|live|dead|stopped|expired|missing|offline
 	LastError string `yaml:"last_error" json:"last_error"`
 }
 
 // State represents the persisted state
 type State struct {
-	Items []*Upstream `yaml:"items"`
-	Next  int         `yaml:"next"`
+	Items       []*Upstream         `yaml:"items"`
+	Next        int                 `yaml:"next"`
+	PACProfiles []*PACProfile       `yaml:"pac_profiles,omitempty"`
+	RemoteLists []*RemoteList       `yaml:"remote_lists,omitempty"`
+	Schedules   []*ProviderSchedule `yaml:"provider_syncs,omitempty"`
+	ACL         *DestACL            `yaml:"acl,omitempty"` // global destination ACL
 }
 
 // Manager manages all proxy items
@@ -45,8 +84,26 @@ type Manager struct {
 	mu       sync.RWMutex
 	items    map[string]*ProxyItem // id -> ProxyItem
 	nextPort int
+	pac      map[string]*PACProfile // name -> PAC profile
+
+	remote       map[string]*RemoteList // name -> remote list provider config
+	remoteStatus map[string]*SyncStatus // name -> last sync
+
+	schedules      map[string]*ProviderSchedule // provider -> scheduled sync
+	scheduleStatus map[string]*SyncStatus       // provider -> last scheduled sync
+
+	jobs    jobStore     // background vendor orders
+	expiry  expiryState  // guarded by mu
+	secrets *secretStore // provider credentials, encrypted at rest
+
+	acl         *DestACL                    // global destination ACL as configured
+	aclCompiled atomic.Pointer[compiledACL] // read by listeners without the lock
 
-	adminToken string
+	adminToken       string
+	requireLocalAuth bool                 // every listener gets credentials (LOCAL_AUTH or LAN mode)
+	lan              *lanConfig           // LAN sharing mode, nil = loopback only; set before any listener starts
+	lastBackup       map[string]time.Time // reason -> last snapshot time
+	stateHash        [32]byte             // sha256 of the state file as last read or written
 }
 
 // ProxyItem holds runtime data for a single proxy
@@ -61,35 +118,37 @@ type ProxyItem struct {
 
 // CloudMiniProxyItem represents a proxy from CloudMini API
 type CloudMiniProxyItem struct {
-	IP       string `json:"ip"`        // format: "hostname:port"
-	HTTPS    string `json:"https"`     // the actual proxy port
-	User     string `json:"user"`      // username for auth
-	Password string `json:"password"`  // password for auth
+	IP       string `json:"ip"`       // format: "hostname:port"
+	HTTPS    string `json:"https"`    // the actual proxy port
+	User     string `json:"user"`     // username for auth
+	Password string `json:"password"` // password for auth
 }
 
 // CloudMiniOrderResponse represents the response from CloudMini order API
 type CloudMiniOrderResponse struct {
-	Code int                    `json:"code"`
-	Msg  string                 `json:"msg"`
-	Data []CloudMiniProxyItem   `json:"data"`
+	Code int                  `json:"code"`
+	Msg  string               `json:"msg"`
+	Data []CloudMiniProxyItem `json:"data"`
 }
 
 // CloudMiniProxyFull represents a full proxy item from /proxy endpoint
 type CloudMiniProxyFull struct {
-	PK       int    `json:"pk"`
-	IP       string `json:"ip"`
-	HTTPS    string `json:"https"`
-	Socks    string `json:"socks"`
-	User     string `json:"user"`
-	Password string `json:"password"`
-	Location string `json:"location"` // Already exists
-	Status   string `json:"status"`
-	Price    int    `json:"price"`
+	PK        int    `json:"pk"`
+	IP        string `json:"ip"`
+	HTTPS     string `json:"https"`
+	Socks     string `json:"socks"`
+	User      string `json:"user"`
+	Password  string `json:"password"`
+	Location  string `json:"location"` // Already exists
+	Status    string `json:"status"`
+	Price     int    `json:"price"`
+	OrderID   any    `json:"order_id"`   // string or number
+	ExpiredAt any    `json:"expired_at"` // date string or unix seconds
 }
 
 // CloudMiniRegionResponse represents the region config response
 type CloudMiniRegionResponse struct {
-	Error bool `json:"error"`
+	Error bool   `json:"error"`
 	Msg   string `json:"msg"`
 	Data  []struct {
 		Type   string   `json:"type"`
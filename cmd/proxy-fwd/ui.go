@@ -70,9 +70,22 @@ const indexHTML = `<!doctype html>
         <p class="text-sm text-gray-500">Proxy Forward Dashboard</p>
       </div>
       <div class="flex items-center gap-2">
+        <select id="exportFormat" class="px-3 py-2 border border-gray-300 rounded-lg text-sm">
+          <option value="plain">ip:port</option>
+          <option value="url">URL</option>
+          <option value="csv">CSV</option>
+          <option value="pac">PAC</option>
+          <option value="proxifier">Proxifier</option>
+          <option value="chrome">Chrome args</option>
+          <option value="clash">Clash</option>
+        </select>
         <button onclick="handleExport()" class="px-4 py-2 border border-gray-300 rounded-lg hover:bg-gray-50">
           <span>📦 Export Local</span>
         </button>
+        <button onclick="document.getElementById('importFile').click()" class="px-4 py-2 border border-gray-300 rounded-lg hover:bg-gray-50">
+          <span>📂 Import File</span>
+        </button>
+        <input id="importFile" type="file" class="hidden" accept=".txt,.csv,.json,.ppx,.bak,.yaml,.yml" onchange="handleImportFile(this)">
         <button onclick="openBulkModal()" class="px-4 py-2 bg-purple-600 text-white rounded-lg hover:bg-purple-700">
           <span>📥 Bulk Add</span>
         </button>
@@ -267,6 +280,7 @@ const indexHTML = `<!doctype html>
     var toastEl = document.getElementById('toast');
     var tokenInput = document.getElementById('tokenInput');
     var DATA = { items: [] };
+    var LOCAL_HOST = '127.0.0.1';
     var autoRefreshTimer = null;
 
     tokenInput.value = localStorage.getItem('admintoken') || '';
@@ -275,26 +289,54 @@ const indexHTML = `<!doctype html>
       showToast('Token saved');
     });
 
-    // Load CloudMini Token from localStorage
-    var cloudminiTokenInput = document.getElementById('cloudminiToken');
-    if(cloudminiTokenInput){
-      cloudminiTokenInput.value = localStorage.getItem('cloudmini_token') || '';
-      cloudminiTokenInput.addEventListener('change', function(){
-        localStorage.setItem('cloudmini_token', cloudminiTokenInput.value.trim());
-        showToast('CloudMini Token saved');
+    // The CloudMini token is stored on the server (encrypted); the inputs
+    // only replace it and are cleared once it is saved
+    var cloudminiTokenInputs = ['cloudminiToken', 'cloudminiSyncToken'].map(function(id){
+      return document.getElementById(id);
+    }).filter(Boolean);
+
+    function showCloudMiniTokenState(stored){
+      cloudminiTokenInputs.forEach(function(input){
+        input.value = '';
+        input.placeholder = stored ? 'CloudMini token stored on server (type to replace)' : 'CloudMini API Token';
       });
     }
 
-    // Load CloudMini Sync Token from localStorage
-    var cloudminiSyncTokenInput = document.getElementById('cloudminiSyncToken');
-    if(cloudminiSyncTokenInput){
-      cloudminiSyncTokenInput.value = localStorage.getItem('cloudmini_token') || '';
-      cloudminiSyncTokenInput.addEventListener('change', function(){
-        localStorage.setItem('cloudmini_token', cloudminiSyncTokenInput.value.trim());
-        showToast('CloudMini Token saved');
+    function saveCloudMiniToken(token){
+      return fetch('/api/v1/secrets/cloudmini', {
+        method: 'PUT',
+        headers: Object.assign(hdr(), {'Content-Type': 'application/json'}),
+        body: JSON.stringify({value: token})
+      }).then(function(r){
+        if(!r.ok) return r.text().then(function(t){ throw new Error(t); });
+        showCloudMiniTokenState(true);
       });
     }
 
+    cloudminiTokenInputs.forEach(function(input){
+      input.addEventListener('change', function(){
+        var token = input.value.trim();
+        if(!token) return;
+        saveCloudMiniToken(token).then(function(){
+          showToast('CloudMini Token saved on server');
+        }).catch(function(e){
+          showToast('CloudMini Token Error: ' + e.message);
+        });
+      });
+    });
+
+    // move a token kept by older versions in the browser to the server
+    var legacyCloudMiniToken = localStorage.getItem('cloudmini_token');
+    if(legacyCloudMiniToken){
+      saveCloudMiniToken(legacyCloudMiniToken).then(function(){
+        localStorage.removeItem('cloudmini_token');
+      }).catch(function(){});
+    } else {
+      GET('/api/v1/secrets').then(function(res){
+        showCloudMiniTokenState((res.items || []).some(function(s){ return s.name === 'cloudmini'; }));
+      }).catch(function(){});
+    }
+
     function hdr(){ 
       var t = localStorage.getItem('admintoken') || ''; 
       var h = {}; 
@@ -318,6 +360,17 @@ const indexHTML = `<!doctype html>
       }); 
     }
 
+    // appendExpiry adds the vendor expiry date under a cell, red within 3 days
+    function appendExpiry(td, it){
+      if(!it.expires_at) return;
+      var exp = new Date(it.expires_at);
+      var soon = exp - Date.now() < 3 * 86400000;
+      var div = document.createElement('div');
+      div.className = soon ? 'text-red-600 font-medium' : 'text-gray-400';
+      div.textContent = 'exp ' + exp.toISOString().slice(0, 10);
+      td.appendChild(div);
+    }
+
     function showToast(msg){
       toastEl.textContent = msg;
       toastEl.classList.remove('hidden');
@@ -388,7 +441,7 @@ const indexHTML = `<!doctype html>
       tdType.appendChild(typeBadge);
       tr.appendChild(tdType);
 
-      var local = '127.0.0.1:' + it.local_port;
+      var local = LOCAL_HOST + ':' + it.local_port;
       var tdLocal = document.createElement('td'); 
       tdLocal.className = 'py-3 px-4';
       var localDiv = document.createElement('div');
@@ -526,6 +579,12 @@ const indexHTML = `<!doctype html>
         var tdUp = document.createElement('td');
         tdUp.className = 'py-3 px-4 font-mono text-sm';
         tdUp.textContent = proxyAddr;
+        if(it.socks_port){
+          var socks = document.createElement('div');
+          socks.className = 'text-xs text-gray-400';
+          socks.textContent = 'socks5 :' + it.socks_port;
+          tdUp.appendChild(socks);
+        }
         tr.appendChild(tdUp);
         
         // Type column
@@ -544,13 +603,16 @@ const indexHTML = `<!doctype html>
         var tdLocation = document.createElement('td');
         tdLocation.className = 'py-3 px-4 text-xs text-gray-600';
         tdLocation.textContent = it.location || '-';
+        appendExpiry(tdLocation, it);
         tr.appendChild(tdLocation);
         
         var tdStatus = document.createElement('td');
         tdStatus.className = 'py-3 px-4';
         var badge = document.createElement('span');
         badge.className = 'status-inactive';
-        badge.textContent = 'In Pool';
+        badge.textContent = it.status === 'expired' ? 'Expired' : it.status === 'missing' ? 'Gone at vendor' :
+          it.status === 'offline' ? 'Offline at vendor' : 'In Pool';
+        if(it.vendor_status) badge.title = 'Vendor status: ' + it.vendor_status;
         tdStatus.appendChild(badge);
         tr.appendChild(tdStatus);
         
@@ -582,22 +644,13 @@ const indexHTML = `<!doctype html>
         return;
       }
       
-      var ok = 0, fail = 0;
-      function startNext(i){
-        if(i >= pool.length){
-          showToast('Started: ' + ok + ' ok, ' + fail + ' fail');
-          reload();
-          return;
-        }
-        POST('/api/start?id=' + encodeURIComponent(pool[i].id)).then(function(){
-          ok++;
-          startNext(i+1);
-        }).catch(function(){
-          fail++;
-          startNext(i+1);
-        });
-      }
-      startNext(0);
+      var ids = pool.map(function(it){ return it.id; });
+      POST('/api/bulk/start', JSON.stringify({ids: ids})).then(function(res){
+        showToast('Started: ' + res.ok + ' ok, ' + res.failed + ' fail');
+        reload();
+      }).catch(function(e){
+        showToast('Error: ' + e.message);
+      });
     }
 
     function clearPool(){
@@ -612,22 +665,13 @@ const indexHTML = `<!doctype html>
       
       if(!confirm('Delete all ' + pool.length + ' proxies in pool?')) return;
       
-      var ok = 0, fail = 0;
-      function delNext(i){
-        if(i >= pool.length){
-          showToast('Deleted: ' + ok + ' ok, ' + fail + ' fail');
-          reload();
-          return;
-        }
-        POST('/api/remove?id=' + encodeURIComponent(pool[i].id)).then(function(){
-          ok++;
-          delNext(i+1);
-        }).catch(function(){
-          fail++;
-          delNext(i+1);
-        });
-      }
-      delNext(0);
+      var ids = pool.map(function(it){ return it.id; });
+      POST('/api/bulk/remove', JSON.stringify({ids: ids})).then(function(res){
+        showToast('Deleted: ' + res.ok + ' ok, ' + res.failed + ' fail');
+        reload();
+      }).catch(function(e){
+        showToast('Error: ' + e.message);
+      });
     }
 
     function filterPool(){
@@ -711,6 +755,7 @@ const indexHTML = `<!doctype html>
         var tdLocation = document.createElement('td');
         tdLocation.className = 'py-3 px-4 text-xs text-gray-600';
         tdLocation.textContent = it.location || '-';
+        appendExpiry(tdLocation, it);
         tr.appendChild(tdLocation);
         
         var tdStatus = document.createElement('td');
@@ -763,7 +808,7 @@ const indexHTML = `<!doctype html>
       
       var filtered = activeProxies.filter(function(it){
         var up = (it.user ? it.user + ':' + it.pass + '@' : '') + it.host + ':' + it.port;
-        var local = '127.0.0.1:' + it.local_port;
+        var local = LOCAL_HOST + ':' + it.local_port;
         var type = it.proxy_type || 'unknown';
         return up.toLowerCase().indexOf(query) !== -1 || 
                local.indexOf(query) !== -1 || 
@@ -785,7 +830,29 @@ const indexHTML = `<!doctype html>
     }
 
     function handleExport(){
-      window.open('/api/export-local', '_blank');
+      var f = document.getElementById('exportFormat').value;
+      window.open('/api/export-local?format=' + f + (f === 'plain' || f === 'url' || f === 'chrome' ? '' : '&download=1'), '_blank');
+    }
+
+    function handleImportFile(input){
+      var file = input.files[0];
+      input.value = '';
+      if(!file) return;
+      file.text().then(function(text){
+        var url = '/api/import?name=' + encodeURIComponent(file.name);
+        return POST(url + '&dry_run=1', text).then(function(rep){
+          var msg = 'Import ' + file.name + ' (' + rep.format + '):\n' +
+            rep.added + ' to add, ' + rep.updated + ' to update, ' + rep.skipped + ' skipped, ' + rep.failed + ' invalid';
+          if(!rep.added && !rep.updated){ showToast(msg.replace('\n', ' ')); return; }
+          if(!confirm(msg + '\n\nContinue?')) return;
+          return POST(url, text).then(function(res){
+            showToast('Imported: ' + res.added + ' added, ' + res.updated + ' updated');
+            reload();
+          });
+        });
+      }).catch(function(e){
+        showToast('Error: ' + e.message);
+      });
     }
 
     function openBulkModal(){
@@ -800,24 +867,14 @@ const indexHTML = `<!doctype html>
       var text = document.getElementById('bulkTextarea').value;
       var lines = text.split('\n').map(function(s){ return s.trim(); }).filter(Boolean);
       if(!lines.length){ showToast('No lines'); return; }
-      var ok = 0, fail = 0;
-      function addLine(i){
-        if(i >= lines.length){
-          closeBulkModal();
-          document.getElementById('bulkTextarea').value = '';
-          showToast('Done: ' + ok + ' ok, ' + fail + ' fail');
-          reload();
-          return;
-        }
-        POST('/api/add', lines[i]).then(function(){
-          ok++;
-          addLine(i+1);
-        }).catch(function(){
-          fail++;
-          addLine(i+1);
-        });
-      }
-      addLine(0);
+      POST('/api/bulk/add', JSON.stringify({lines: lines, start: true})).then(function(res){
+        closeBulkModal();
+        document.getElementById('bulkTextarea').value = '';
+        showToast('Done: ' + res.ok + ' ok, ' + res.failed + ' fail');
+        reload();
+      }).catch(function(e){
+        showToast('Error: ' + e.message);
+      });
     }
 
     function startProxy(id){
@@ -860,12 +917,9 @@ const indexHTML = `<!doctype html>
     }
 
     function loadCloudminiRegions(){
-      var token = document.getElementById('cloudminiToken').value.trim();
       var type = document.getElementById('cloudminiType').value;
       
-      if(!token){ showToast('Enter CloudMini Token'); return; }
-      
-      var url = '/api/cloudmini/regions?token=' + encodeURIComponent(token) + '&type=' + type;
+      var url = '/api/cloudmini/regions?type=' + type;
       
       showToast('Loading regions...');
       
@@ -901,13 +955,11 @@ const indexHTML = `<!doctype html>
     }
 
     function handleCloudMiniOrder(){
-      var token = document.getElementById('cloudminiToken').value.trim();
       var type = document.getElementById('cloudminiType').value;
       var region = document.getElementById('cloudminiRegion').value;
       var quantity = document.getElementById('cloudminiQuantity').value;
       var autoStart = document.getElementById('cloudminiAutoStart').checked;
       
-      if(!token){ showToast('Enter CloudMini Token'); return; }
       if(!region){ showToast('Select region'); return; }
       if(!quantity || quantity < 1){ showToast('Enter quantity'); return; }
       
@@ -916,15 +968,16 @@ const indexHTML = `<!doctype html>
         type: type,
         region: region,
         quantity: parseInt(quantity),
-        period: 30
+        period: 30,
+        start: autoStart
       });
       
-      showToast('Ordering from CloudMini... (this may take up to 5 minutes)');
+      showToast('Ordering from CloudMini...');
       
       var headers = hdr();
-      headers['X-CloudMini-Token'] = token;
       headers['Content-Type'] = 'application/json';
       
+      // the order runs as a background job; poll it until it finishes
       fetch(url, {
         method: 'POST',
         headers: headers,
@@ -932,51 +985,41 @@ const indexHTML = `<!doctype html>
       }).then(function(r){
         if(!r.ok) return r.text().then(function(t){ throw new Error(t); });
         return r.json();
-      }).then(function(result){
-        if(!result.data || !result.data.length){
-          throw new Error(result.msg || 'No proxies returned');
+      }).then(function(job){
+        showToast('CloudMini order queued (job ' + job.id + ')');
+        pollCloudMiniJob(job.id, '');
+      }).catch(function(e){
+        showToast('CloudMini Error: ' + e.message);
+      });
+    }
+
+    function pollCloudMiniJob(id, last){
+      GET('/api/v1/jobs/' + encodeURIComponent(id)).then(function(job){
+        if(job.status === 'done'){
+          var res = job.result || {};
+          showToast('CloudMini: ' + (res.added || 0) + ' added, ' + (res.existing || 0) + ' existing' +
+            (res.errors && res.errors.length ? ', ' + res.errors.length + ' errors' : ''));
+          reload();
+          return;
         }
-        
-        showToast('CloudMini order complete! Adding ' + result.data.length + ' proxies...');
-        
-        var ok = 0, fail = 0;
-        var proxies = result.data;
-        
-        function addProxy(i){
-          if(i >= proxies.length){
-            showToast('CloudMini: ' + ok + ' added, ' + fail + ' failed');
-            reload();
-            return;
-          }
-          
-          var item = proxies[i];
-          // Parse CloudMini format: ip="hostname:port", https=port, user=username
-          var hostname = item.ip.split(':')[0];
-          var proxyLine = hostname + ':' + item.https + ':' + item.user + ':' + item.password;
-          
-          var endpoint = autoStart ? '/api/add' : '/api/add-pool';
-          
-          POST(endpoint, proxyLine).then(function(){
-            ok++;
-            addProxy(i+1);
-          }).catch(function(){
-            fail++;
-            addProxy(i+1);
-          });
+        if(job.status === 'failed' || job.status === 'canceled'){
+          showToast('CloudMini order ' + job.status + (job.error ? ': ' + job.error : ''));
+          return;
         }
-        
-        addProxy(0);
+        var orders = job.orders || [];
+        var ready = orders.filter(function(o){ return o.status === 'ready' || o.status === 'done'; }).length;
+        var progress = job.status + ' (' + ready + '/' + orders.length + ' orders ready)';
+        if(progress !== last){
+          showToast('CloudMini order: ' + progress);
+        }
+        setTimeout(function(){ pollCloudMiniJob(id, progress); }, 3000);
       }).catch(function(e){
         showToast('CloudMini Error: ' + e.message);
       });
     }
 
     function handleCloudMiniSyncToPool(){
-      var token = document.getElementById('cloudminiSyncToken').value.trim();
-      
-      if(!token){ showToast('Enter CloudMini Token'); return; }
-      
-      var url = '/api/cloudmini/sync?token=' + encodeURIComponent(token);
+      var url = '/api/cloudmini/sync';
       
       showToast('Syncing from CloudMini... (this may take a while)');
       
@@ -986,7 +1029,8 @@ const indexHTML = `<!doctype html>
         if(!r.ok) return r.text().then(function(t){ throw new Error(t); });
         return r.json();
       }).then(function(result){
-        var msg = 'CloudMini Sync: ' + result.total + ' total, ' + result.added + ' added, ' + result.existing + ' existing';
+        var msg = 'CloudMini Sync: ' + result.total + ' total, ' + result.added + ' added, ' + result.existing + ' existing, ' +
+          result.updated + ' updated, ' + result.offline + ' offline, ' + result.missing + ' gone at vendor';
         if(result.errors && result.errors.length > 0){
           msg += ', ' + result.errors.length + ' errors';
         }
@@ -997,6 +1041,10 @@ const indexHTML = `<!doctype html>
       });
     }
 
+    // listener host differs from 127.0.0.1 in LAN mode
+    GET('/api/v1/lan').then(function(lan){
+      if(lan.advertise && lan.advertise !== LOCAL_HOST){ LOCAL_HOST = lan.advertise; reload(); }
+    }).catch(function(){});
     reload();
   </script>
 </body>
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"crypto/sha256"
+	"log"
+	"os"
+	"reflect"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// watchState polls the state file and reconciles external edits into the
+// running set. Our own writes are recognised by the hash kept in saveState.
+func (m *Manager) watchState(interval time.Duration) {
+	var lastMod time.Time
+	var lastSize int64
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for range t.C {
+		fi, err := os.Stat(stateFile)
+		if err != nil {
+			continue
+		}
+		if fi.ModTime().Equal(lastMod) && fi.Size() == lastSize {
+			continue
+		}
+		lastMod, lastSize = fi.ModTime(), fi.Size()
+		if err := m.reloadState(); err != nil {
+			log.Printf("[Watch] reload %s: %v", stateFile, err)
+		}
+	}
+}
+
+// reloadState re-reads the state file and applies it if it was modified
+// outside this process. The file is read under the lock so it cannot be
+// confused with a save in progress.
+func (m *Manager) reloadState() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, err := os.ReadFile(stateFile)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(b)
+	if sum == m.stateHash {
+		return nil
+	}
+	var st State
+	if err := yaml.Unmarshal(b, &st); err != nil {
+		return err
+	}
+	log.Printf("[Watch] external change detected in %s", stateFile)
+	if _, err := m.snapshotLocked("external", true); err != nil {
+		log.Printf("[Watch] snapshot before reload: %v", err)
+	}
+	m.reconcileLocked(&st)
+	return m.saveState()
+}
+
+// reconcileLocked merges a state read from disk into the running set
+// (must be called with Manager lock held). New items go to the pool, changed
+// upstreams restart their listener on the same local port, removed items are
+// stopped. Items whose configuration did not change are left untouched. The
+// other sections replace the running ones and are logged when they changed.
+func (m *Manager) reconcileLocked(st *State) {
+	seen := make(map[string]bool, len(st.Items))
+	var added, updated, restarted, removed int
+	for _, up := range st.Items {
+		if up == nil || up.Host == "" {
+			continue
+		}
+		if up.ID == "" {
+			// hand-written entries may leave the ID out, as in the add API
+			up.ID = sanitizeID(up.Host, up.Port)
+		}
+		seen[up.ID] = true
+		it, ok := m.items[up.ID]
+		if !ok {
+			if up.ProxyType == "" {
+				up.ProxyType = detectProxyType(up.Host)
+			}
+			up.LocalPort = 0
+			up.Status = "stopped"
+			up.LastError = ""
+			m.items[up.ID] = &ProxyItem{cfg: up}
+			added++
+			continue
+		}
+		fields := upstreamDiff(it.cfg, up)
+		if len(fields) == 0 {
+			continue
+		}
+		needRestart := it.isRunning && changesUpstream(fields)
+		copyUpstreamConfig(it.cfg, up)
+		updated++
+		if needRestart {
+			if err := m.restartLocked(it); err != nil {
+				log.Printf("[Watch] restart %s: %v", up.ID, err)
+			}
+			restarted++
+		}
+	}
+	for id, it := range m.items {
+		if seen[id] {
+			continue
+		}
+		if it.isRunning {
+			_ = m.stopLocked(it)
+		}
+		delete(m.items, id)
+		removed++
+	}
+	if !sameSection(m.pacProfilesLocked(), st.PACProfiles) {
+		log.Printf("[Watch] pac_profiles reloaded (%d profiles)", len(st.PACProfiles))
+	}
+	m.setPACProfilesLocked(st.PACProfiles)
+	if !sameSection(m.remoteListsLocked(), st.RemoteLists) {
+		log.Printf("[Watch] remote_lists reloaded (%d lists)", len(st.RemoteLists))
+	}
+	m.setRemoteListsLocked(st.RemoteLists) // the caller saves, dropping plaintext credentials
+	if !sameSection(m.schedulesLocked(), st.Schedules) {
+		log.Printf("[Watch] provider_syncs reloaded (%d schedules)", len(st.Schedules))
+	}
+	m.setSchedulesLocked(st.Schedules)
+	if !aclEqual(m.acl, st.ACL) {
+		log.Printf("[Watch] acl reloaded")
+	}
+	m.loadGlobalACLLocked(st.ACL)
+	if st.Next > m.nextPort {
+		m.nextPort = st.Next
+	}
+	log.Printf("[Watch] reconciled: %d added, %d updated (%d restarted), %d removed", added, updated, restarted, removed)
+}
+
+// sameSection compares a section of the running state with the one read from
+// disk, treating nil and empty alike
+func sameSection[T any](running, disk []T) bool {
+	if len(running) == 0 || len(disk) == 0 {
+		return len(running) == len(disk)
+	}
+	return reflect.DeepEqual(running, disk)
+}
+
+// changesUpstream reports whether any of the changed fields affect the
+// upstream connection or health watcher, i.e. require restarting a running listener
+func changesUpstream(fields []string) bool {
+	for _, f := range fields {
+		switch f {
+		case "host", "port", "scheme", "user", "pass", "health_url", "health_interval", "health_fail_limit", "routes", "acl", "local_auth":
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestReloadStateDerivesMissingID(t *testing.T) {
+	m := newTestManager(t)
+	if err := m.saveState(); err != nil {
+		t.Fatal(err)
+	}
+	edit := "items:\n  - host: 1.2.3.4\n    port: 8080\n    user: u\n    pass: p\n"
+	if err := os.WriteFile(stateFile, []byte(edit), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.reloadState(); err != nil {
+		t.Fatal(err)
+	}
+	it, ok := m.items["1-2-3-4-8080"]
+	if !ok {
+		t.Fatalf("items = %v, want 1-2-3-4-8080", m.items)
+	}
+	if it.cfg.ID != "1-2-3-4-8080" || it.cfg.Status != "stopped" {
+		t.Errorf("cfg = %+v", it.cfg)
+	}
+}
+
+// writeStateEdit replaces proxies.yaml as an editor would and reloads it
+func writeStateEdit(t *testing.T, m *Manager, edit string) {
+	t.Helper()
+	if err := os.WriteFile(stateFile, []byte(edit), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.reloadState(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReloadStateAppliesSections(t *testing.T) {
+	m := newTestManager(t)
+	if err := m.saveState(); err != nil {
+		t.Fatal(err)
+	}
+	writeStateEdit(t, m, `
+pac_profiles:
+  - name: work
+    rules:
+      - match: [corp.example]
+        target: DIRECT
+`)
+	if p := m.pac["work"]; p == nil || len(p.Rules) != 1 {
+		t.Errorf("pac profile work = %+v, want the edited profile", p)
+	}
+
+	writeStateEdit(t, m, `
+acl:
+  deny:
+    cidrs: [10.0.0.0/8]
+`)
+	if m.acl == nil || m.aclCompiled.Load().check("10.1.2.3", 80) == "" {
+		t.Errorf("global acl = %+v, want the edited deny list in force", m.acl)
+	}
+
+	writeStateEdit(t, m, `
+remote_lists:
+  - name: test-reload
+    url: http://127.0.0.1:1/list
+    headers:
+      Authorization: Bearer edited-token
+`)
+	defer m.deleteRemoteList("test-reload")
+	if l := m.remote["test-reload"]; l == nil || l.SecretRef == "" {
+		t.Errorf("remote list test-reload = %+v, want it loaded with its credentials in the secrets store", l)
+	}
+	if _, ok := getProvider("test-reload"); !ok {
+		t.Error("remote list test-reload is not registered as a provider")
+	}
+	if b, _ := os.ReadFile(stateFile); strings.Contains(string(b), "edited-token") {
+		t.Error("proxies.yaml still holds the plaintext header after the reload")
+	}
+
+	writeStateEdit(t, m, `
+provider_syncs:
+  - provider: cloudmini
+    interval: 600
+`)
+	if s := m.schedules["cloudmini"]; s == nil || s.Interval != 600 || s.Missing != "mark" {
+		t.Errorf("schedule cloudmini = %+v, want the edited schedule with defaults", s)
+	}
+
+	writeStateEdit(t, m, "next: 10000\n")
+	if len(m.pac) != 0 {
+		t.Errorf("pac profiles = %v, want them removed", m.pac)
+	}
+	if m.acl != nil || m.aclCompiled.Load().check("10.1.2.3", 80) != "" {
+		t.Errorf("global acl = %+v, want it removed", m.acl)
+	}
+	if _, ok := getProvider("test-reload"); ok {
+		t.Error("remote list test-reload is still registered after its removal")
+	}
+	if len(m.schedules) != 0 {
+		t.Errorf("schedules = %v, want them removed", m.schedules)
+	}
+}